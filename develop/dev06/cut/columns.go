@@ -0,0 +1,123 @@
+// колонки фиксированной ширины: разбор параметров --widths и --columns и разделение строк
+package cut
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Column - колонка фиксированной ширины. Позиции отсчитываются в символах (рунах) от нуля,
+// End указывает на позицию после последнего символа колонки, End = -1 - колонка до конца строки.
+type Column struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// ParseWidths парсит параметр --widths: список ширин колонок через запятую, например "5,10,3".
+// Колонки идут подряд без промежутков, последняя ширина может быть указана как "*" (до конца строки).
+func ParseWidths(input string) ([]Column, error) {
+	var (
+		tokens = strings.Split(input, ",")
+		columns = make([]Column, 0, len(tokens))
+		pos = 0
+	)
+	for i, token := range tokens {
+		if token == "*" && i == len(tokens)-1 {
+			columns = append(columns, Column{Start: pos, End: -1})
+			break
+		}
+		var width, err = strconv.Atoi(token)
+		if err != nil || width < 1 {
+			return nil, &ParseError{Token: token, Reason: "column width must be a positive number"}
+		}
+		columns = append(columns, Column{Start: pos, End: pos + width})
+		pos += width
+	}
+	return columns, nil
+}
+
+// ParseColumns парсит параметр --columns: список колонок вида N-M[:name] через запятую,
+// где N и M - номера первого и последнего символа колонки, начиная с 1, например "1-5:id,6-15:name".
+// Вместо M можно ничего не указывать (N-), тогда колонка продолжается до конца строки.
+func ParseColumns(input string) ([]Column, error) {
+	var columns []Column
+	for _, token := range strings.Split(input, ",") {
+		var column Column
+		var position = token
+		if i := strings.Index(token, ":"); i >= 0 {
+			position, column.Name = token[:i], token[i+1:]
+			if column.Name == "" {
+				return nil, &ParseError{Token: token, Reason: "empty column name"}
+			}
+		}
+
+		var ends = strings.Split(position, "-")
+		if len(ends) != 2 {
+			return nil, &ParseError{Token: token, Reason: "column must be written as N-M or N-"}
+		}
+		var start, err = strconv.Atoi(ends[0])
+		if err != nil || start < 1 {
+			return nil, &ParseError{Token: token, Reason: "invalid column start"}
+		}
+		column.Start, column.End = start-1, -1
+		if ends[1] != "" {
+			var end, err = strconv.Atoi(ends[1])
+			if err != nil || end < start {
+				return nil, &ParseError{Token: token, Reason: "invalid column end"}
+			}
+			column.End = end
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// columnNames возвращает имена колонок, или nil, если ни одна колонка не названа.
+// Безымянные колонки получают в качестве имени свой номер, начиная с 1.
+func columnNames(columns []Column) []string {
+	var named = false
+	var names = make([]string, len(columns))
+	for i, column := range columns {
+		if column.Name != "" {
+			named = true
+			names[i] = column.Name
+		} else {
+			names[i] = strconv.Itoa(i + 1)
+		}
+	}
+	if !named {
+		return nil
+	}
+	return names
+}
+
+// splitColumns делит строку line на колонки фиксированной ширины.
+// Колонки, выходящие за конец строки, укорачиваются или оказываются пустыми.
+// Если trim = true, у значений отбрасываются пробельные символы по краям.
+func splitColumns(line string, columns []Column, trim bool) []string {
+	// смещения в байтах начала каждого символа строки, последний элемент - длина строки
+	var offsets = make([]int, 0, len(line)+1)
+	for i := range line {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(line))
+	var runes = len(offsets) - 1
+
+	var fields = make([]string, len(columns))
+	for i, column := range columns {
+		var start, end = column.Start, column.End
+		if end < 0 || end > runes {
+			end = runes
+		}
+		if start > end {
+			start = end
+		}
+		var value = line[offsets[start]:offsets[end]]
+		if trim {
+			value = strings.TrimSpace(value)
+		}
+		fields[i] = value
+	}
+	return fields
+}
@@ -0,0 +1,112 @@
+package cut
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseWidths(t *testing.T) {
+	var testCases = []struct{
+		input string
+		expected []Column
+		expectedError error
+	}{
+		{
+			"5,10,3",
+			[]Column{{Start: 0, End: 5}, {Start: 5, End: 15}, {Start: 15, End: 18}},
+			nil,
+		},
+		{
+			"2,*",
+			[]Column{{Start: 0, End: 2}, {Start: 2, End: -1}},
+			nil,
+		},
+		{
+			"2,0",
+			nil,
+			ErrInvalidFieldRange,
+		},
+		{
+			"*,2",
+			nil,
+			ErrInvalidFieldRange,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var result, err = ParseWidths(testCase.input)
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("testing %q, expected: %v, got: %v", testCase.input, testCase.expected, result)
+		}
+		if !errors.Is(err, testCase.expectedError) {
+			t.Errorf("testing %q, expected error: %v, got: %v", testCase.input, testCase.expectedError, err)
+		}
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	var testCases = []struct{
+		input string
+		expected []Column
+		expectedError error
+	}{
+		{
+			"1-5:id,6-15:name",
+			[]Column{{Name: "id", Start: 0, End: 5}, {Name: "name", Start: 5, End: 15}},
+			nil,
+		},
+		{
+			"3-4,7-",
+			[]Column{{Start: 2, End: 4}, {Start: 6, End: -1}},
+			nil,
+		},
+		{
+			"5-3:id",
+			nil,
+			ErrInvalidFieldRange,
+		},
+		{
+			"1-5:",
+			nil,
+			ErrInvalidFieldRange,
+		},
+		{
+			"5:id",
+			nil,
+			ErrInvalidFieldRange,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var result, err = ParseColumns(testCase.input)
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("testing %q, expected: %v, got: %v", testCase.input, testCase.expected, result)
+		}
+		if !errors.Is(err, testCase.expectedError) {
+			t.Errorf("testing %q, expected error: %v, got: %v", testCase.input, testCase.expectedError, err)
+		}
+	}
+}
+
+func TestSplitColumns(t *testing.T) {
+	var columns = []Column{{Start: 0, End: 3}, {Start: 3, End: 8}, {Start: 8, End: -1}}
+
+	var testCases = []struct{
+		input string
+		trim bool
+		expected []string
+	}{
+		{"001Bob  rest", false, []string{"001", "Bob  ", "rest"}},
+		{"001Bob  rest", true, []string{"001", "Bob", "rest"}},
+		{"001Бор  хвост", true, []string{"001", "Бор", "хвост"}},
+		{"00", false, []string{"00", "", ""}},
+	}
+
+	for _, testCase := range testCases {
+		var result = splitColumns(testCase.input, columns, testCase.trim)
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("testing %q, expected: %q, got: %q", testCase.input, testCase.expected, result)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package cut
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -288,134 +289,6 @@ func TestCutLine(t *testing.T) {
 }
 
 
-func TestParseIndexIntervals(t *testing.T) {
-	var testCases = []struct{
-		input []string
-		expected []int
-		expectedError error
-	}{
-		{
-			[]string{"15", "4", "2", "10"},
-			[]int{2, 4, 10, 15},
-			nil,
-		},
-		{
-			[]string{"15", "4", "-2", "10"},
-			nil,
-			ErrInvalidFieldRange,
-		},
-		{
-			[]string{},
-			nil,
-			nil,
-		},
-		{
-			[]string{"15", "4", "asdfasdf", "10"},
-			nil,
-			ErrInvalidFieldRange,
-		},
-		{
-			[]string{"15", "4", "7-9", "10"},
-			[]int{4, 7, 8, 9, 10, 15},
-			nil,
-		},
-		{
-			[]string{"9-15", "10-17"},
-			[]int{9, 10, 11, 12, 13, 14, 15, 16, 17},
-			nil,
-		},
-	}
-
-	for _, testCase := range testCases {
-		result, err := parseIndexIntervals(testCase.input)
-		if !reflect.DeepEqual(result, testCase.expected) {
-			t.Errorf("testing %v, expected: %v, got: %v", testCase.input, testCase.expected, result)
-		}
-		if err != testCase.expectedError {
-			t.Errorf("testing %v, expected error: %v, got: %v", testCase.input, testCase.expectedError, err)
-		}
-	}
-}
-
-
-func TestParseFromIntervals(t *testing.T) {
-	var testCases = []struct{
-		input []string
-		expected int
-		expectedError error
-	}{
-		{
-			[]string{"15-", "4-", "2-", "10-"},
-			2,
-			nil,
-		},
-		{
-			[]string{},
-			-1,
-			nil,
-		},
-		{
-			[]string{"15-", "4-", "-2-", "10-"},
-			-1,
-			ErrInvalidFieldRange,
-		},
-		{
-			[]string{"15-", "4-", "asdfasdf", "10-"},
-			-1,
-			ErrInvalidFieldRange,
-		},
-	}
-
-	for _, testCase := range testCases {
-		result, err := parseFromIntervals(testCase.input)
-		if !reflect.DeepEqual(result, testCase.expected) {
-			t.Errorf("testing %v, expected: %v, got: %v", testCase.input, testCase.expected, result)
-		}
-		if err != testCase.expectedError {
-			t.Errorf("testing %v, expected error: %v, got: %v", testCase.input, testCase.expectedError, err)
-		}
-	}
-}
-
-func TestParseUntilIntervals(t *testing.T) {
-	var testCases = []struct{
-		input []string
-		expected int
-		expectedError error
-	}{
-		{
-			[]string{"-15", "-4", "-2", "-10"},
-			15,
-			nil,
-		},
-		{
-			[]string{},
-			-1,
-			nil,
-		},
-		{
-			[]string{"-15", "-4", "--2", "-10"},
-			-1,
-			ErrInvalidFieldRange,
-		},
-		{
-			[]string{"15", "4", "asdfasdf", "10"},
-			-1,
-			ErrInvalidFieldRange,
-		},
-	}
-
-	for _, testCase := range testCases {
-		result, err := parseUntilIntervals(testCase.input)
-		if !reflect.DeepEqual(result, testCase.expected) {
-			t.Errorf("testing %v, expected: %v, got: %v", testCase.input, testCase.expected, result)
-		}
-		if err != testCase.expectedError {
-			t.Errorf("testing %v, expected error: %v, got: %v", testCase.input, testCase.expectedError, err)
-		}
-	}
-}
-
 func TestParseIntervals(t *testing.T) {
 	var testCases = []struct{
 		name string
@@ -561,7 +434,7 @@ func TestParseIntervals(t *testing.T) {
 			t.Errorf("failed test %q, expected until: %d, got: %d", testCase.name, testCase.expectedUntil, until)
 		}
 
-		if err != testCase.expectedError {
+		if !errors.Is(err, testCase.expectedError) {
 			t.Errorf("failed test %q, expected error: %v, got: %v", testCase.name, testCase.expectedError, err)
 		}
 		
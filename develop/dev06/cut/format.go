@@ -0,0 +1,97 @@
+// форматы вывода выбранных полей
+package cut
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format - формат вывода выбранных полей
+type Format int
+
+const (
+	FormatText Format = iota // поля через выходной разделитель, как есть
+	FormatTSV                // поля через табуляцию, табуляции, переводы строк и \ в значениях экранируются
+	FormatJSON               // по одному JSON-объекту на строку, ключи - имена полей
+)
+
+// ParseFormat переводит название формата (text, tsv, json) в Format
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "text", "":
+		return FormatText, nil
+	case "tsv":
+		return FormatTSV, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return FormatText, fmt.Errorf("unknown output format %q", name)
+	}
+}
+
+// tsvEscaper экранирует значения полей для формата TSV
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// writeRecord записывает в w поля fields с индексами indices в формате format.
+// names - имена полей для формата JSON, поля без имени получают свой номер, начиная с 1.
+func writeRecord(w *bufio.Writer, fields []string, indices []int, names []string, format Format, delimeter string) error {
+	switch format {
+	case FormatJSON:
+		return writeJSONRecord(w, fields, indices, names)
+	case FormatTSV:
+		for i, index := range indices {
+			if i > 0 {
+				w.WriteByte('\t')
+			}
+			tsvEscaper.WriteString(w, fields[index])
+		}
+	default:
+		for i, index := range indices {
+			if i > 0 {
+				w.WriteString(delimeter)
+			}
+			w.WriteString(fields[index])
+		}
+	}
+	return w.WriteByte('\n')
+}
+
+// writeJSONRecord записывает в w поля как один JSON-объект с сохранением порядка полей
+func writeJSONRecord(w *bufio.Writer, fields []string, indices []int, names []string) error {
+	w.WriteByte('{')
+	for i, index := range indices {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		var name = strconv.Itoa(index + 1)
+		if index < len(names) {
+			name = names[index]
+		}
+		if err := writeJSONValue(w, name); err != nil {
+			return err
+		}
+		w.WriteByte(':')
+		if err := writeJSONValue(w, fields[index]); err != nil {
+			return err
+		}
+	}
+	w.WriteString("}\n")
+	return nil
+}
+
+// writeJSONValue записывает в w значение value в компактном JSON без экранирования HTML-символов
+func writeJSONValue(w io.Writer, value interface{}) error {
+	var buffer = bytes.Buffer{}
+	var encoder = json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.TrimSuffix(buffer.Bytes(), []byte("\n")))
+	return err
+}
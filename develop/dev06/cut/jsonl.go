@@ -0,0 +1,198 @@
+// режим JSON Lines: каждая строка - JSON-значение, поля выбираются путями вида user.id или tags[0]
+package cut
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// pathSegment - один шаг пути: ключ объекта или индекс массива
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// JSONPath - путь к значению внутри JSON-документа, например request.path или tags[0]
+type JSONPath struct {
+	raw      string
+	segments []pathSegment
+}
+
+// String возвращает путь в том виде, в котором он был указан
+func (p JSONPath) String() string {
+	return p.raw
+}
+
+// ParseJSONPaths парсит список путей через запятую. Путь состоит из ключей, разделенных
+// точками, и индексов массивов в квадратных скобках: user.id, tags[0], items[2].name.
+// В случае неверного формата возвращается *ParseError с указанием пути.
+func ParseJSONPaths(input string) ([]JSONPath, error) {
+	var paths []JSONPath
+	for _, token := range strings.Split(input, ",") {
+		var path, err = parseJSONPath(token)
+		if err != nil {
+			return nil, &ParseError{Token: token, Reason: err.Error()}
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// parseJSONPath парсит один путь
+func parseJSONPath(token string) (JSONPath, error) {
+	var path = JSONPath{raw: token}
+	var rest = token
+	if rest == "" {
+		return path, errors.New("empty path")
+	}
+
+	for rest != "" {
+		if rest[0] == '[' {
+			var end = strings.IndexByte(rest, ']')
+			if end < 0 {
+				return path, errors.New("unclosed [")
+			}
+			var index, err = strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return path, errors.New("array index must be a non-negative number")
+			}
+			path.segments = append(path.segments, pathSegment{index: index, isIndex: true})
+			rest = rest[end+1:]
+			if strings.HasPrefix(rest, ".") {
+				rest = rest[1:]
+				if rest == "" || rest[0] == '[' || rest[0] == '.' {
+					return path, errors.New("key expected after .")
+				}
+			} else if rest != "" && rest[0] != '[' {
+				return path, errors.New("unexpected " + strconv.Quote(rest))
+			}
+			continue
+		}
+
+		var end = strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return path, errors.New("empty key")
+		}
+		path.segments = append(path.segments, pathSegment{key: rest[:end]})
+		rest = rest[end:]
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" {
+				return path, errors.New("key expected after .")
+			}
+		}
+	}
+	return path, nil
+}
+
+// lookup находит значение по пути в разобранном документе
+func (p JSONPath) lookup(value interface{}) (interface{}, bool) {
+	for _, segment := range p.segments {
+		if segment.isIndex {
+			var array, ok = value.([]interface{})
+			if !ok || segment.index >= len(array) {
+				return nil, false
+			}
+			value = array[segment.index]
+		} else {
+			var object, ok = value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[segment.key]; !ok {
+				return nil, false
+			}
+		}
+	}
+	return value, true
+}
+
+// decodeJSONLine разбирает строку как одно JSON-значение, числа сохраняются в исходной записи
+func decodeJSONLine(line string) (interface{}, bool) {
+	var decoder = json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	// после значения в строке не должно быть ничего, кроме пробелов: даже лишняя
+	// закрывающая скобка должна приводить к ошибке, а не к концу ввода
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return nil, false
+	}
+	return value, true
+}
+
+// jsonText возвращает текстовое представление значения для вывода в колонку:
+// строки выводятся без кавычек, null и отсутствующие значения - пустой строкой,
+// объекты и массивы - компактным JSON
+func jsonText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		var buffer = bytes.Buffer{}
+		writeJSONValue(&buffer, v)
+		return buffer.String()
+	}
+}
+
+// writeJSONLine проецирует одну строку JSON Lines на пути params.Paths и записывает результат в w.
+// Строки, не являющиеся корректным JSON, пропускаются при params.Strict или выводятся как есть,
+// если не заданы условия params.Where.
+func writeJSONLine(w *bufio.Writer, line string, params Parameters) error {
+	var document, ok = decodeJSONLine(line)
+	if !ok {
+		if params.Strict || params.Where != nil {
+			return nil
+		}
+		w.WriteString(line)
+		return w.WriteByte('\n')
+	}
+	for _, condition := range params.Where {
+		if !condition.matchJSON(document) {
+			return nil
+		}
+	}
+
+	if params.Format == FormatJSON {
+		w.WriteByte('{')
+		for i, path := range params.Paths {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeJSONValue(w, path.String())
+			w.WriteByte(':')
+			var value, _ = path.lookup(document)
+			if err := writeJSONValue(w, value); err != nil {
+				return err
+			}
+		}
+		_, err := w.WriteString("}\n")
+		return err
+	}
+
+	var fields = make([]string, len(params.Paths))
+	var indices = make([]int, len(params.Paths))
+	for i, path := range params.Paths {
+		var value, _ = path.lookup(document)
+		fields[i] = jsonText(value)
+		indices[i] = i
+	}
+	return writeRecord(w, fields, indices, nil, params.Format, params.OutputDelimeter)
+}
@@ -0,0 +1,158 @@
+// отбор строк: по номерам строк (--lines) и по условиям на значения полей (--where)
+package cut
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseLines парсит список номеров строк в той же записи, что и список полей для ParseIntervals:
+// N, N-M, N- и -N. Строки нумеруются с 1 отдельно в каждом входном файле.
+func ParseLines(input string) (*FieldSpec, error) {
+	var spec, err = ParseFieldSpec(input, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range spec.ranges {
+		if r.start.name != "" {
+			return nil, &ParseError{Token: r.start.name, Reason: "line number expected"}
+		}
+	}
+	return spec, nil
+}
+
+// containsLine сообщает, входит ли строка с номером num (с 1) в список строк
+func (s *FieldSpec) containsLine(num int) bool {
+	for _, r := range s.ranges {
+		if (r.start.open || num >= r.start.num) && (r.end.open || num <= r.end.num) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastLine возвращает номер последней строки, входящей в список, или -1, если список не ограничен
+func (s *FieldSpec) lastLine() int {
+	var last = -1
+	for _, r := range s.ranges {
+		if r.end.open {
+			return -1
+		}
+		if r.end.num > last {
+			last = r.end.num
+		}
+	}
+	return last
+}
+
+// операторы условий в порядке поиска: двухсимвольные проверяются раньше односимвольных
+var conditionOperators = []string{"!=", "=", "<", ">", "~"}
+
+// Condition - условие отбора строк вида FIELD OP VALUE.
+// Операторы: = и != сравнивают строки, < и > сравнивают числа, если оба значения - числа,
+// и строки в противном случае, ~ проверяет соответствие значения регулярному выражению.
+// Строки, в которых поля нет, условию не удовлетворяют.
+type Condition struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+	spec  *FieldSpec // поле в режимах с разделителем и с колонками фиксированной ширины
+	path  JSONPath   // поле в режиме JSON Lines
+}
+
+// ParseCondition парсит условие вида FIELD OP VALUE (например "3>10", "name = bob", "path~^/api").
+// FIELD записывается так же, как один элемент списка полей -f (номер, номер с конца при negative
+// или имя поля), а в режиме jsonl - как путь к значению.
+func ParseCondition(input string, negative bool, jsonl bool) (*Condition, error) {
+	var c = &Condition{}
+	var at = -1
+	for i := 0; i < len(input) && at < 0; i++ {
+		for _, op := range conditionOperators {
+			if strings.HasPrefix(input[i:], op) {
+				c.op, at = op, i
+				break
+			}
+		}
+	}
+	if at < 0 {
+		return nil, &ParseError{Token: input, Reason: "operator expected (=, !=, <, >, ~)"}
+	}
+	c.field = strings.TrimSpace(input[:at])
+	c.value = strings.TrimSpace(input[at+len(c.op):])
+
+	if jsonl {
+		var path, err = parseJSONPath(c.field)
+		if err != nil {
+			return nil, &ParseError{Token: input, Reason: err.Error()}
+		}
+		c.path = path
+	} else {
+		var r, err = parseFieldRange(c.field, negative)
+		if err != nil {
+			return nil, &ParseError{Token: input, Reason: err.Error()}
+		}
+		if r.start != r.end {
+			return nil, &ParseError{Token: input, Reason: "single field expected"}
+		}
+		c.spec = &FieldSpec{ranges: []fieldRange{r}}
+	}
+
+	if c.op == "~" {
+		var re, err = regexp.Compile(c.value)
+		if err != nil {
+			return nil, &ParseError{Token: input, Reason: err.Error()}
+		}
+		c.re = re
+	}
+	return c, nil
+}
+
+// match проверяет условие на значении поля
+func (c *Condition) match(value string) bool {
+	switch c.op {
+	case "=":
+		return value == c.value
+	case "!=":
+		return value != c.value
+	case "~":
+		return c.re.MatchString(value)
+	}
+
+	var a, errA = strconv.ParseFloat(value, 64)
+	var b, errB = strconv.ParseFloat(c.value, 64)
+	if errA == nil && errB == nil {
+		if c.op == "<" {
+			return a < b
+		}
+		return a > b
+	}
+	if c.op == "<" {
+		return value < c.value
+	}
+	return value > c.value
+}
+
+// matchFields проверяет условие на строке, разделенной на поля
+func (c *Condition) matchFields(fields []string) bool {
+	var indices = c.spec.Indices(len(fields))
+	if len(indices) == 0 {
+		return false
+	}
+	return c.match(fields[indices[0]])
+}
+
+// matchJSON проверяет условие на разобранном JSON-документе
+func (c *Condition) matchJSON(document interface{}) bool {
+	var value, ok = c.path.lookup(document)
+	if !ok {
+		return false
+	}
+	return c.match(jsonText(value))
+}
+
+// Named сообщает, ссылается ли условие на поле по имени, для которого нужен заголовок
+func (c *Condition) Named() bool {
+	return c.spec != nil && c.spec.Named()
+}
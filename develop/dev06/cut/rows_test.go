@@ -0,0 +1,166 @@
+package cut
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLines(t *testing.T) {
+	var spec, err = ParseLines("1,10-20,100-")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+
+	var testCases = []struct{
+		num int
+		expected bool
+	}{
+		{1, true},
+		{2, false},
+		{10, true},
+		{20, true},
+		{21, false},
+		{99, false},
+		{100, true},
+		{5000, true},
+	}
+	for _, testCase := range testCases {
+		if result := spec.containsLine(testCase.num); result != testCase.expected {
+			t.Errorf("testing line %d, expected: %v, got: %v", testCase.num, testCase.expected, result)
+		}
+	}
+
+	if last := spec.lastLine(); last != -1 {
+		t.Errorf("expected unbounded last line, got: %d", last)
+	}
+	spec, _ = ParseLines("-3,7")
+	if last := spec.lastLine(); last != 7 {
+		t.Errorf("expected last line 7, got: %d", last)
+	}
+
+	if _, err := ParseLines("1,name"); !errors.Is(err, ErrInvalidFieldRange) {
+		t.Errorf("expected ErrInvalidFieldRange for names, got: %v", err)
+	}
+}
+
+func TestConditionMatch(t *testing.T) {
+	var fields = []string{"bob", "30", "/api/users", "9"}
+
+	var testCases = []struct{
+		input string
+		expected bool
+	}{
+		{"1=bob", true},
+		{"1 = bob", true},
+		{"1!=bob", false},
+		{"2>4", true},   // числовое сравнение
+		{"4<10", true},  // числовое сравнение, строки сравнились бы наоборот
+		{"1<carl", true}, // строковое сравнение
+		{"3~^/api/", true},
+		{"3~^/web/", false},
+		{"7=x", false},  // поля нет
+		{"7!=x", false}, // поля нет
+	}
+
+	for _, testCase := range testCases {
+		var condition, err = ParseCondition(testCase.input, false, false)
+		if err != nil {
+			t.Errorf("testing %q, expected no error, got: %s", testCase.input, err)
+			continue
+		}
+		if result := condition.matchFields(fields); result != testCase.expected {
+			t.Errorf("testing %q, expected: %v, got: %v", testCase.input, testCase.expected, result)
+		}
+	}
+
+	var invalid = []string{"1", "1-3=x", "x~(", "=x"}
+	for _, input := range invalid {
+		if _, err := ParseCondition(input, false, false); !errors.Is(err, ErrInvalidFieldRange) {
+			t.Errorf("testing %q, expected ErrInvalidFieldRange, got: %v", input, err)
+		}
+	}
+}
+
+func TestCutRows(t *testing.T) {
+	var input = "name,age\nbob,30\nal,4\ncarl,51\ndan,17\n"
+
+	var testCases = []struct{
+		name string
+		lines string
+		where []string
+		expected string
+	}{
+		{
+			name: "lines",
+			lines: "2,4-",
+			expected: "name,age\nal,4\ndan,17\n",
+		},
+		{
+			name: "lines after header",
+			lines: "1-3",
+			expected: "name,age\nbob,30\nal,4\ncarl,51\n",
+		},
+		{
+			name: "where",
+			where: []string{"age>20"},
+			expected: "name,age\nbob,30\ncarl,51\n",
+		},
+		{
+			name: "lines and where",
+			lines: "-4",
+			where: []string{"age>20", "name~^c"},
+			expected: "name,age\ncarl,51\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		var spec, _ = ParseFieldSpec("1-", false)
+		var params = Parameters{
+			Fields: spec,
+			Delimeter: ",",
+			Header: true,
+		}
+		if testCase.lines != "" {
+			params.Lines, _ = ParseLines(testCase.lines)
+		}
+		for _, input := range testCase.where {
+			var condition, err = ParseCondition(input, false, false)
+			if err != nil {
+				t.Fatalf("failed test %q, expected no error, got: %s", testCase.name, err)
+			}
+			params.Where = append(params.Where, condition)
+		}
+
+		var out = &bytes.Buffer{}
+		if err := Cut(strings.NewReader(input), out, params); err != nil {
+			t.Errorf("failed test %q, expected no error, got: %s", testCase.name, err)
+		}
+		if out.String() != testCase.expected {
+			t.Errorf("failed test %q, expected: %q, got: %q", testCase.name, testCase.expected, out.String())
+		}
+	}
+}
+
+func TestCutRowsJSONL(t *testing.T) {
+	var paths, _ = ParseJSONPaths("id")
+	var condition, err = ParseCondition("user.age > 18", false, true)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+
+	var out = &bytes.Buffer{}
+	var input = "{\"id\":1,\"user\":{\"age\":30}}\nbad\n{\"id\":2,\"user\":{\"age\":3}}\n"
+	err = Cut(strings.NewReader(input), out, Parameters{
+		Paths: paths,
+		Delimeter: "\t",
+		Where: []*Condition{condition},
+	})
+	if err != nil {
+		t.Errorf("expected no error, got: %s", err)
+	}
+	if out.String() != "1\n" {
+		t.Errorf("expected: %q, got: %q", "1\n", out.String())
+	}
+}
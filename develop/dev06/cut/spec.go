@@ -0,0 +1,257 @@
+// расширенный список полей: интервалы с отсчетом от конца строки и имена полей из заголовка
+package cut
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseError - ошибка разбора списка полей с указанием токена, на котором произошла ошибка.
+// Оборачивает ErrInvalidFieldRange, так что errors.Is(err, ErrInvalidFieldRange) остается верным.
+type ParseError struct {
+	Token  string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %q: %s", ErrInvalidFieldRange, e.Token, e.Reason)
+}
+
+func (e *ParseError) Unwrap() error {
+	return ErrInvalidFieldRange
+}
+
+// bound - одна граница интервала полей
+type bound struct {
+	num     int    // номер поля, начиная с 1
+	fromEnd bool   // num отсчитывается от конца строки (-1 - последнее поле)
+	name    string // имя поля из заголовка; если указано, num и fromEnd не используются
+	open    bool   // граница отсутствует (интервал открыт с этой стороны)
+}
+
+// fieldRange - интервал полей [start, end], одиночное поле задается интервалом из одного элемента
+type fieldRange struct {
+	start bound
+	end   bound
+}
+
+// FieldSpec - разобранный список полей. В отличие от ParseIntervals, допускает
+// номера полей с конца строки и имена полей, поэтому конкретные индексы
+// вычисляются для каждой строки отдельно методом Indices.
+type FieldSpec struct {
+	ranges []fieldRange
+	names  map[string]int // индексы (с нуля) полей заголовка, заполняется в Resolve
+}
+
+// ParseFieldSpec парсит список полей. Элементы списка разделяются запятыми и имеют вид:
+//  N, N-M, N- - номера и интервалы номеров полей, начиная с 1
+//  -N - все поля от первого до N-го (как в POSIX cut), если negative = false
+//  -N, -N-, -N--M, N--M - номера с конца строки (-1 - последнее поле), если negative = true;
+//   в этом режиме интервал "от начала до N" записывается как 1-N
+//  name - имя поля из заголовка (элемент, не начинающийся с цифры или минуса, целиком считается именем)
+// Двойной минус однозначно отделяет вторую границу с конца от первой, а одиночный -N
+// трактуется в зависимости от negative, так что старые списки полей не меняют смысла.
+// В случае неверного формата возвращается *ParseError с указанием элемента.
+func ParseFieldSpec(input string, negative bool) (*FieldSpec, error) {
+	var spec = &FieldSpec{}
+	for _, token := range strings.Split(input, ",") {
+		var r, err = parseFieldRange(token, negative)
+		if err != nil {
+			return nil, err
+		}
+		spec.ranges = append(spec.ranges, r)
+	}
+	return spec, nil
+}
+
+// parseFieldRange парсит один элемент списка полей
+func parseFieldRange(token string, negative bool) (fieldRange, error) {
+	if token == "" {
+		return fieldRange{}, &ParseError{Token: token, Reason: "empty field"}
+	}
+	if token[0] != '-' && (token[0] < '0' || token[0] > '9') {
+		var b = bound{name: token}
+		return fieldRange{start: b, end: b}, nil
+	}
+
+	var r fieldRange
+	var rest = token
+
+	if rest[0] == '-' && !negative {
+		r.start = bound{open: true}
+	} else {
+		var b, tail, err = parseBound(rest, negative)
+		if err != nil {
+			return fieldRange{}, &ParseError{Token: token, Reason: err.Error()}
+		}
+		r.start, rest = b, tail
+		if rest == "" {
+			r.end = r.start
+			return r, nil
+		}
+	}
+
+	if rest[0] != '-' {
+		return fieldRange{}, &ParseError{Token: token, Reason: "unexpected " + strconv.Quote(rest)}
+	}
+	rest = rest[1:]
+	if rest == "" {
+		if r.start.open {
+			return fieldRange{}, &ParseError{Token: token, Reason: "both ends are open"}
+		}
+		r.end = bound{open: true}
+		return r, nil
+	}
+
+	var b, tail, err = parseBound(rest, negative)
+	if err != nil {
+		return fieldRange{}, &ParseError{Token: token, Reason: err.Error()}
+	}
+	if tail != "" {
+		return fieldRange{}, &ParseError{Token: token, Reason: "unexpected " + strconv.Quote(tail)}
+	}
+	r.end = b
+
+	if !r.start.open && r.start.fromEnd == r.end.fromEnd {
+		if (!r.start.fromEnd && r.end.num < r.start.num) || (r.start.fromEnd && r.end.num > r.start.num) {
+			return fieldRange{}, &ParseError{Token: token, Reason: "range end is before its start"}
+		}
+	}
+	return r, nil
+}
+
+// parseBound считывает из начала s номер поля (с минусом, если negative и отсчет идет с конца),
+// возвращает границу и оставшуюся часть строки
+func parseBound(s string, negative bool) (bound, string, error) {
+	var b bound
+	if negative && strings.HasPrefix(s, "-") {
+		b.fromEnd = true
+		s = s[1:]
+	}
+
+	var i = 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return bound{}, "", fmt.Errorf("field number expected")
+	}
+
+	var n, err = strconv.Atoi(s[:i])
+	if err != nil {
+		return bound{}, "", fmt.Errorf("invalid field number %q", s[:i])
+	}
+	if n < 1 {
+		return bound{}, "", fmt.Errorf("fields are numbered from 1")
+	}
+	b.num = n
+	return b, s[i:], nil
+}
+
+// Named сообщает, содержит ли список полей имена, для которых нужен заголовок
+func (s *FieldSpec) Named() bool {
+	for _, r := range s.ranges {
+		if r.start.name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve связывает имена полей в списке с полями заголовка header.
+// Возвращает ошибку, если какое-либо имя в заголовке отсутствует.
+func (s *FieldSpec) Resolve(header []string) error {
+	s.names = make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := s.names[name]; !ok {
+			s.names[name] = i
+		}
+	}
+	for _, r := range s.ranges {
+		if r.start.name == "" {
+			continue
+		}
+		if _, ok := s.names[r.start.name]; !ok {
+			return fmt.Errorf("unknown field name %q", r.start.name)
+		}
+	}
+	return nil
+}
+
+// Indices возвращает упорядоченные уникальные индексы (с нуля) выбранных полей
+// для строки, состоящей из n полей. Индексы за пределами строки отбрасываются.
+func (s *FieldSpec) Indices(n int) []int {
+	var selected = make([]bool, n)
+	for _, r := range s.ranges {
+		var start, end = s.index(r.start, 0, n), s.index(r.end, n-1, n)
+		if start < 0 {
+			start = 0
+		}
+		if end > n-1 {
+			end = n - 1
+		}
+		for i := start; i <= end; i++ {
+			selected[i] = true
+		}
+	}
+
+	var indices []int
+	for i, ok := range selected {
+		if ok {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// index переводит границу в индекс с нуля для строки из n полей,
+// open - значение для открытой границы
+func (s *FieldSpec) index(b bound, open int, n int) int {
+	switch {
+	case b.open:
+		return open
+	case b.name != "":
+		if i, ok := s.names[b.name]; ok {
+			return i
+		}
+		return n // имя не разрешено - поле за пределами строки
+	case b.fromEnd:
+		return n - b.num
+	default:
+		return b.num - 1
+	}
+}
+
+// writeFields записывает в w поля строки line, разделенной delimeter, с индексами indices,
+// разделяя их outDelimeter. Строка не разбивается на слайс полей: поля находятся
+// последовательным поиском разделителя и пишутся в w по одному.
+func writeFields(w io.StringWriter, line string, indices []int, delimeter string, outDelimeter string) error {
+	var next = 0 // позиция следующего выводимого индекса в indices
+	for i := 0; next < len(indices); i++ {
+		var field = line
+		var end = strings.Index(line, delimeter)
+		if end >= 0 {
+			field = line[:end]
+		}
+
+		if indices[next] == i {
+			if next > 0 {
+				if _, err := w.WriteString(outDelimeter); err != nil {
+					return err
+				}
+			}
+			if _, err := w.WriteString(field); err != nil {
+				return err
+			}
+			next++
+		}
+
+		if end < 0 {
+			break
+		}
+		line = line[end+len(delimeter):]
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package cut
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldSpecIndices(t *testing.T) {
+	var testCases = []struct{
+		name string
+		input string
+		negative bool
+		fields int
+		expected []int
+	}{
+		{
+			name: "plain indices",
+			input: "3,1,2",
+			fields: 5,
+			expected: []int{0, 1, 2},
+		},
+		{
+			name: "until without negative",
+			input: "-2",
+			fields: 5,
+			expected: []int{0, 1},
+		},
+		{
+			name: "last field",
+			input: "-1",
+			negative: true,
+			fields: 5,
+			expected: []int{4},
+		},
+		{
+			name: "last three",
+			input: "-3--1",
+			negative: true,
+			fields: 5,
+			expected: []int{2, 3, 4},
+		},
+		{
+			name: "from second to second last",
+			input: "2--2",
+			negative: true,
+			fields: 5,
+			expected: []int{1, 2, 3},
+		},
+		{
+			name: "open from end",
+			input: "-2-",
+			negative: true,
+			fields: 5,
+			expected: []int{3, 4},
+		},
+		{
+			name: "until in negative mode",
+			input: "1-2,-1",
+			negative: true,
+			fields: 5,
+			expected: []int{0, 1, 4},
+		},
+		{
+			name: "from end beyond line",
+			input: "-10--4",
+			negative: true,
+			fields: 5,
+			expected: []int{0, 1},
+		},
+		{
+			name: "out of bounds",
+			input: "7,9-",
+			fields: 5,
+			expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var spec, err = ParseFieldSpec(testCase.input, testCase.negative)
+		if err != nil {
+			t.Errorf("failed test %q, expected no error, got: %s", testCase.name, err)
+			continue
+		}
+		var indices = spec.Indices(testCase.fields)
+		if !reflect.DeepEqual(indices, testCase.expected) {
+			t.Errorf("failed test %q, expected: %v, got: %v", testCase.name, testCase.expected, indices)
+		}
+	}
+}
+
+func TestParseFieldSpecErrors(t *testing.T) {
+	var testCases = []struct{
+		input string
+		negative bool
+		token string
+	}{
+		{"1,2x,3", false, "2x"},
+		{"1,,3", false, ""},
+		{"5-2", false, "5-2"},
+		{"-1--3", true, "-1--3"},
+		{"-", false, "-"},
+		{"1-2-3", false, "1-2-3"},
+		{"0", false, "0"},
+		{"--1", false, "--1"},
+	}
+
+	for _, testCase := range testCases {
+		var _, err = ParseFieldSpec(testCase.input, testCase.negative)
+		if !errors.Is(err, ErrInvalidFieldRange) {
+			t.Errorf("testing %q, expected ErrInvalidFieldRange, got: %v", testCase.input, err)
+			continue
+		}
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Token != testCase.token {
+			t.Errorf("testing %q, expected error for token %q, got: %v", testCase.input, testCase.token, err)
+		}
+	}
+}
+
+func TestFieldSpecNames(t *testing.T) {
+	var spec, err = ParseFieldSpec("name,-1,id", true)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if !spec.Named() {
+		t.Errorf("expected spec to be named")
+	}
+
+	var header = strings.Split("id:name:age:city", ":")
+	if err := spec.Resolve(header); err != nil {
+		t.Fatalf("expected no error on resolve, got: %s", err)
+	}
+
+	if indices := spec.Indices(len(header)); !reflect.DeepEqual(indices, []int{0, 1, 3}) {
+		t.Errorf("expected: %v, got: %v", []int{0, 1, 3}, indices)
+	}
+
+	spec, _ = ParseFieldSpec("email", false)
+	if err := spec.Resolve(header); err == nil {
+		t.Errorf("expected error on unknown field name")
+	}
+}
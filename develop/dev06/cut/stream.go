@@ -0,0 +1,170 @@
+// потоковая обработка входных данных: чтение строк произвольной длины и вывод выбранных полей
+package cut
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Parameters - структура для хранения параметров выполнения cut
+type Parameters struct {
+	Fields          *FieldSpec
+	Delimeter       string
+	OutputDelimeter string   // разделитель полей на выходе, если пуст - используется Delimeter
+	Strict          bool     // игнорировать строки без разделителя
+	Header          bool     // первая строка задает имена полей для Fields
+	Columns         []Column // колонки фиксированной ширины; если заданы, Delimeter для разбора строк не используется
+	Trim            bool     // отбрасывать пробельные символы по краям значений колонок фиксированной ширины
+	Format          Format
+	Paths           []JSONPath // режим JSON Lines: выводятся значения по этим путям, Fields не используется
+	Lines           *FieldSpec   // номера выводимых строк (ParseLines), nil - все строки
+	Where           []*Condition // выводятся только строки, удовлетворяющие всем условиям
+}
+
+// Cut читает строки из in и записывает в out поля, выбранные params.Fields.
+// Длина строк не ограничена. При params.Header первая строка связывается с именами
+// полей в params.Fields и выводится так же, как остальные строки (кроме формата JSON,
+// где она задает ключи объектов). Без заголовка именами полей служат имена колонок
+// фиксированной ширины, если они указаны. Если заданы params.Paths, каждая строка
+// разбирается как JSON, а заголовок и Fields не используются.
+// Строки отбираются по номерам params.Lines и условиям params.Where, заголовок выводится всегда
+// и не учитывается в нумерации строк: строка 1 - первая строка данных. Строки без разделителя и строки с некорректным JSON
+// проверяются условиями как строки из одного поля и без полей соответственно.
+// Строки, выведенные до ошибки чтения или разбора, записываются в out.
+func Cut(in io.Reader, out io.Writer, params Parameters) error {
+	var writer = bufio.NewWriter(out)
+	var err = cut(bufio.NewReader(in), writer, params)
+	if flushErr := writer.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// cut выполняет Cut, записывая результат в буфер writer
+func cut(reader *bufio.Reader, writer *bufio.Writer, params Parameters) error {
+	var (
+		first  = true
+		names  = columnNames(params.Columns)
+		num    = 0 // номер текущей строки данных
+		last   = -1
+	)
+
+	if params.OutputDelimeter == "" {
+		params.OutputDelimeter = params.Delimeter
+	}
+	if params.Lines != nil {
+		last = params.Lines.lastLine()
+	}
+	if names != nil && !params.Header && params.Paths == nil {
+		if err := resolveNames(names, params); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var line, err = reader.ReadString('\n')
+		var header = first && params.Header && params.Paths == nil
+		if line != "" && !header {
+			num++
+		}
+		var selected = params.Lines == nil || params.Lines.containsLine(num)
+
+		if line != "" && params.Paths != nil {
+			if selected {
+				if err := writeJSONLine(writer, strings.TrimSuffix(line, "\n"), params); err != nil {
+					return err
+				}
+			}
+		} else if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			if header {
+				names = splitLine(line, params)
+				if err := resolveNames(names, params); err != nil {
+					return err
+				}
+				if params.Format != FormatJSON {
+					if err := writeRecord(writer, names, params.Fields.Indices(len(names)), names, params.Format, params.OutputDelimeter); err != nil {
+						return err
+					}
+				}
+			} else if selected {
+				if err := writeLine(writer, line, names, params); err != nil {
+					return err
+				}
+			}
+			first = false
+		}
+
+		// дальше нет выбранных строк - оставшийся ввод можно не читать
+		if last > 0 && num >= last {
+			break
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveNames связывает имена полей с полями и условиями отбора
+func resolveNames(names []string, params Parameters) error {
+	if err := params.Fields.Resolve(names); err != nil {
+		return err
+	}
+	for _, condition := range params.Where {
+		if err := condition.spec.Resolve(names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitLine делит строку на поля по разделителю или по колонкам фиксированной ширины
+func splitLine(line string, params Parameters) []string {
+	if params.Columns != nil {
+		return splitColumns(line, params.Columns, params.Trim)
+	}
+	return strings.Split(line, params.Delimeter)
+}
+
+// writeLine записывает в w выбранные поля одной строки (без символа перевода строки на входе)
+func writeLine(w *bufio.Writer, line string, names []string, params Parameters) error {
+	if params.Where != nil {
+		var fields = splitLine(line, params)
+		for _, condition := range params.Where {
+			if !condition.matchFields(fields) {
+				return nil
+			}
+		}
+	}
+
+	if params.Columns == nil && !strings.Contains(line, params.Delimeter) {
+		if params.Strict {
+			return nil
+		}
+		if params.Format == FormatText {
+			if _, err := w.WriteString(line); err != nil {
+				return err
+			}
+			return w.WriteByte('\n')
+		}
+	}
+
+	// в простейшем случае строка не разбивается на слайс полей
+	if params.Columns == nil && params.Format == FormatText {
+		var indices = params.Fields.Indices(strings.Count(line, params.Delimeter) + 1)
+		if err := writeFields(w, line, indices, params.Delimeter, params.OutputDelimeter); err != nil {
+			return err
+		}
+		return w.WriteByte('\n')
+	}
+
+	var fields = splitLine(line, params)
+	return writeRecord(w, fields, params.Fields.Indices(len(fields)), names, params.Format, params.OutputDelimeter)
+}
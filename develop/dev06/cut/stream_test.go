@@ -0,0 +1,273 @@
+package cut
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCut(t *testing.T) {
+	var long = strings.Repeat("x", 200*1024)
+
+	var testCases = []struct{
+		name string
+		input string
+		fields string
+		header bool
+		strict bool
+		expected string
+	}{
+		{
+			name: "basic",
+			input: "a:b:c\nd:e:f\n",
+			fields: "1,3",
+			expected: "a:c\nd:f\n",
+		},
+		{
+			name: "no trailing newline",
+			input: "a:b:c\nd:e:f",
+			fields: "2",
+			expected: "b\ne\n",
+		},
+		{
+			name: "line without delimeter",
+			input: "a:b\nplain\n",
+			fields: "2",
+			expected: "b\nplain\n",
+		},
+		{
+			name: "strict",
+			input: "a:b\nplain\n",
+			fields: "2",
+			strict: true,
+			expected: "b\n",
+		},
+		{
+			name: "header",
+			input: "id:name\n1:bob\n",
+			fields: "name",
+			header: true,
+			expected: "name\nbob\n",
+		},
+		{
+			name: "long line",
+			input: "a:" + long + ":c\n",
+			fields: "2",
+			expected: long + "\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		var spec, err = ParseFieldSpec(testCase.fields, false)
+		if err != nil {
+			t.Fatalf("failed test %q, expected no parse error, got: %s", testCase.name, err)
+		}
+
+		var out = &bytes.Buffer{}
+		err = Cut(strings.NewReader(testCase.input), out, Parameters{
+			Fields: spec,
+			Delimeter: ":",
+			Strict: testCase.strict,
+			Header: testCase.header,
+		})
+		if err != nil {
+			t.Errorf("failed test %q, expected no error, got: %s", testCase.name, err)
+		}
+		if out.String() != testCase.expected {
+			t.Errorf("failed test %q, expected: %.50q, got: %.50q", testCase.name, testCase.expected, out.String())
+		}
+	}
+}
+
+func TestCutFixedWidth(t *testing.T) {
+	var columns, _ = ParseColumns("1-3:id,4-8:name,9-:city")
+	var input = "001Bob  Paris\n002Al\tx Rome\n"
+
+	var testCases = []struct{
+		name string
+		fields string
+		format Format
+		expected string
+	}{
+		{
+			name: "text",
+			fields: "id,city",
+			format: FormatText,
+			expected: "001;Paris\n002;Rome\n",
+		},
+		{
+			name: "tsv",
+			fields: "1-2",
+			format: FormatTSV,
+			expected: "001\tBob\n002\tAl\\tx\n",
+		},
+		{
+			name: "json",
+			fields: "city,name",
+			format: FormatJSON,
+			expected: "{\"name\":\"Bob\",\"city\":\"Paris\"}\n{\"name\":\"Al\\tx\",\"city\":\"Rome\"}\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		var spec, err = ParseFieldSpec(testCase.fields, false)
+		if err != nil {
+			t.Fatalf("failed test %q, expected no parse error, got: %s", testCase.name, err)
+		}
+
+		var out = &bytes.Buffer{}
+		err = Cut(strings.NewReader(input), out, Parameters{
+			Fields: spec,
+			OutputDelimeter: ";",
+			Columns: columns,
+			Trim: true,
+			Format: testCase.format,
+		})
+		if err != nil {
+			t.Errorf("failed test %q, expected no error, got: %s", testCase.name, err)
+		}
+		if out.String() != testCase.expected {
+			t.Errorf("failed test %q, expected: %q, got: %q", testCase.name, testCase.expected, out.String())
+		}
+	}
+}
+
+func TestCutHeaderJSON(t *testing.T) {
+	var spec, _ = ParseFieldSpec("2,id", false)
+	var out = &bytes.Buffer{}
+	var err = Cut(strings.NewReader("id,name\n1,<b>\n"), out, Parameters{
+		Fields: spec,
+		Delimeter: ",",
+		Header: true,
+		Format: FormatJSON,
+	})
+	if err != nil {
+		t.Errorf("expected no error, got: %s", err)
+	}
+	if out.String() != "{\"id\":\"1\",\"name\":\"<b>\"}\n" {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+}
+
+func TestCutReadError(t *testing.T) {
+	var spec, _ = ParseFieldSpec("2", false)
+	var readErr = errors.New("read failed")
+	var out = &bytes.Buffer{}
+	var err = Cut(io.MultiReader(strings.NewReader("a:b\nc:d\n"), iotest.ErrReader(readErr)), out, Parameters{
+		Fields: spec,
+		Delimeter: ":",
+	})
+	if err != readErr {
+		t.Errorf("expected error %q, got: %v", readErr, err)
+	}
+	// строки, прочитанные до ошибки, должны быть выведены
+	if out.String() != "b\nd\n" {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+}
+
+func TestCutJSONL(t *testing.T) {
+	var input = `{"user":{"id":42,"name":"bob"},"tags":["a","b"],"ok":true}` + "\n" +
+		"not json\n" +
+		`{"user":{"id":7},"request":{"path":"/x"}}` + "\n"
+
+	var testCases = []struct{
+		name string
+		paths string
+		strict bool
+		format Format
+		expected string
+	}{
+		{
+			name: "text",
+			paths: "user.id,tags[1],request.path",
+			format: FormatText,
+			expected: "42\tb\t\nnot json\n7\t\t/x\n",
+		},
+		{
+			name: "strict",
+			paths: "user.id,ok",
+			strict: true,
+			format: FormatText,
+			expected: "42\ttrue\n7\t\n",
+		},
+		{
+			name: "nested value",
+			paths: "user",
+			strict: true,
+			format: FormatText,
+			expected: "{\"id\":42,\"name\":\"bob\"}\n{\"id\":7}\n",
+		},
+		{
+			name: "json",
+			paths: "user.id,tags[0]",
+			strict: true,
+			format: FormatJSON,
+			expected: "{\"user.id\":42,\"tags[0]\":\"a\"}\n{\"user.id\":7,\"tags[0]\":null}\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		var paths, err = ParseJSONPaths(testCase.paths)
+		if err != nil {
+			t.Fatalf("failed test %q, expected no parse error, got: %s", testCase.name, err)
+		}
+
+		var out = &bytes.Buffer{}
+		err = Cut(strings.NewReader(input), out, Parameters{
+			Paths: paths,
+			Delimeter: "\t",
+			Strict: testCase.strict,
+			Format: testCase.format,
+		})
+		if err != nil {
+			t.Errorf("failed test %q, expected no error, got: %s", testCase.name, err)
+		}
+		if out.String() != testCase.expected {
+			t.Errorf("failed test %q, expected: %q, got: %q", testCase.name, testCase.expected, out.String())
+		}
+	}
+}
+
+func TestDecodeJSONLine(t *testing.T) {
+	var testCases = []struct{
+		input string
+		ok bool
+	}{
+		{input: `{"a":1}`, ok: true},
+		{input: ` [1,2] `, ok: true},
+		{input: `"x"`, ok: true},
+		{input: `{"a":1}}`, ok: false},
+		{input: `{"a":1}]`, ok: false},
+		{input: `[1]]`, ok: false},
+		{input: `{"a":1} {"b":2}`, ok: false},
+		{input: `{"a":1} 2`, ok: false},
+		{input: `{"a":`, ok: false},
+		{input: ``, ok: false},
+	}
+
+	for _, testCase := range testCases {
+		if _, ok := decodeJSONLine(testCase.input); ok != testCase.ok {
+			t.Errorf("testing %q, expected ok=%t, got: %t", testCase.input, testCase.ok, ok)
+		}
+	}
+}
+
+func TestParseJSONPaths(t *testing.T) {
+	var valid = []string{"a", "a.b", "a[0]", "a[0].b", "a[1][2]", "[0].a"}
+	for _, input := range valid {
+		if _, err := ParseJSONPaths(input); err != nil {
+			t.Errorf("testing %q, expected no error, got: %s", input, err)
+		}
+	}
+
+	var invalid = []string{"", "a.", "a..b", "a[x]", "a[-1]", "a[0", "a[0]b", ".a"}
+	for _, input := range invalid {
+		if _, err := ParseJSONPaths(input); !errors.Is(err, ErrInvalidFieldRange) {
+			t.Errorf("testing %q, expected ErrInvalidFieldRange, got: %v", input, err)
+		}
+	}
+}
@@ -1,54 +1,180 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	
+	"strings"
+
 	"github.com/rixagis/wb-level-2/develop/dev06/cut"
 )
 
+// openInput открывает файл с именем name, "-" означает стандартный ввод
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
+
+// conditionsFlag - значения повторяемого параметра --where
+type conditionsFlag []string
+
+func (c *conditionsFlag) String() string {
+	return strings.Join(*c, ", ")
+}
+
+func (c *conditionsFlag) Set(value string) error {
+	*c = append(*c, value)
+	return nil
+}
+
+// namedColumns сообщает, есть ли среди колонок фиксированной ширины именованные
+func namedColumns(columns []cut.Column) bool {
+	for _, column := range columns {
+		if column.Name != "" {
+			return true
+		}
+	}
+	return false
+}
 
 func main() {
 	var (
-		fields = flag.String("f", "", "Список номеров колонок или их интервалов (включая открытые)")
+		fields = flag.String("f", "", "Список номеров колонок или их интервалов (включая открытые), а также имен колонок при --header")
 		delimiter = flag.String("d", "\t", "Разделитель колонок")
 		strict = flag.Bool("s", false, "Игнорировать строки без разделителя")
+		header = flag.Bool("header", false, "Первая строка задает имена колонок, которые можно указывать в -f")
+		negative = flag.Bool("negative", false, "Трактовать -N в -f как N-ю колонку с конца (-1 - последняя), а не как колонки с 1 по N")
+		outputDelimiter = flag.String("output-delimiter", "", "Разделитель колонок на выходе (по умолчанию совпадает с -d)")
+		widths = flag.String("widths", "", "Ширины колонок фиксированной ширины через запятую, последняя может быть * (до конца строки)")
+		columnsSpec = flag.String("columns", "", "Колонки фиксированной ширины вида N-M[:имя] через запятую, номера символов с 1")
+		trim = flag.Bool("trim", false, "Отбрасывать пробелы по краям значений колонок фиксированной ширины")
+		format = flag.String("format", "text", "Формат вывода: text, tsv или json")
+		jsonl = flag.Bool("jsonl", false, "Каждая строка - JSON-объект, -f задает пути к значениям (user.id,tags[0])")
+		lines = flag.String("lines", "", "Номера выводимых строк или их интервалов в той же записи, что и -f (1,10-20,100-); строка заголовка --header не учитывается")
+		where conditionsFlag
 	)
+	flag.Var(&where, "where", "Условие отбора строк вида ПОЛЕ ОП ЗНАЧЕНИЕ, ОП - =, !=, <, > или ~ (регулярное выражение); можно указать несколько")
 	flag.Parse()
 
-	if len(flag.Args()) > 0 {
-		fmt.Fprintf(os.Stderr, "Неизвестный параметр: %s\n", flag.Args()[0])
+	if *widths != "" && *columnsSpec != "" {
+		fmt.Fprintln(os.Stderr, "Параметры --widths и --columns несовместимы")
+		os.Exit(1)
+	}
+
+	if *jsonl && (*widths != "" || *columnsSpec != "" || *header || *negative) {
+		fmt.Fprintln(os.Stderr, "Параметр --jsonl несовместим с --widths, --columns, --header и --negative")
+		os.Exit(1)
+	}
+
+	var columns []cut.Column
+	var err error
+	if *widths != "" {
+		columns, err = cut.ParseWidths(*widths)
+	} else if *columnsSpec != "" {
+		columns, err = cut.ParseColumns(*columnsSpec)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Неверный формат записи колонок:", err)
 		os.Exit(1)
 	}
 
+	// в режиме колонок фиксированной ширины по умолчанию выводятся все колонки
+	if *fields == "" && columns != nil {
+		*fields = "1-"
+	}
+
 	if *fields == "" {
 		fmt.Fprintln(os.Stderr, "Параметр -f обязателен")
 		os.Exit(1)
 	}
 
-	var fieldIndices, until, from, err = cut.ParseIntervals(*fields)
+	outputFormat, err := cut.ParseFormat(*format)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Неверный формат записи интервалов")
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	for i := range fieldIndices {
-		fieldIndices[i]-- // единица вычитается для приведения к системе отсчтета от нуля
+	if *delimiter == "" {
+		fmt.Fprintln(os.Stderr, "Разделитель не может быть пустым")
+		os.Exit(1)
 	}
 
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		// единицы вычитаются для приведения к системе отсчтета от нуля
-		var result, ok = cut.CutLine(scanner.Text(), fieldIndices, until-1, from-1, *delimiter, *strict)
-		if ok {
-			fmt.Println(result)
+	var params = cut.Parameters{
+		Delimeter: *delimiter,
+		Strict: *strict,
+		OutputDelimeter: *outputDelimiter,
+		Header: *header,
+		Columns: columns,
+		Trim: *trim,
+		Format: outputFormat,
+	}
+
+	if *jsonl {
+		params.Paths, err = cut.ParseJSONPaths(*fields)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Неверный формат записи путей:", err)
+			os.Exit(1)
+		}
+	} else {
+		params.Fields, err = cut.ParseFieldSpec(*fields, *negative)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Неверный формат записи интервалов:", err)
+			os.Exit(1)
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(2)
+	for _, input := range where {
+		var condition, err = cut.ParseCondition(input, *negative, *jsonl)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Неверный формат записи условия:", err)
+			os.Exit(1)
+		}
+		params.Where = append(params.Where, condition)
+	}
+
+	if *lines != "" {
+		params.Lines, err = cut.ParseLines(*lines)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Неверный формат записи номеров строк:", err)
+			os.Exit(1)
+		}
+	}
+
+	if !*jsonl {
+		var named = params.Fields.Named()
+		for _, condition := range params.Where {
+			named = named || condition.Named()
+		}
+		if named && !*header && !namedColumns(columns) {
+			fmt.Fprintln(os.Stderr, "Имена колонок в -f и --where можно использовать только вместе с --header или именованными --columns")
+			os.Exit(1)
+		}
+	}
+
+	var files = flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	// ошибка в одном файле не прерывает обработку остальных
+	var exitCode = 0
+	for _, name := range files {
+		var in, err = openInput(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cut: %s\n", err)
+			exitCode = 1
+			continue
+		}
+
+		err = cut.Cut(in, os.Stdout, params)
+		in.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cut: %s: %s\n", name, err)
+			exitCode = 1
+		}
 	}
+	os.Exit(exitCode)
 }
\ No newline at end of file
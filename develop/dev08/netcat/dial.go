@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// dial подключается к адресу из позиционных аргументов: HOST PORT напрямую или через прокси (-x),
+// PATH для сокетов Unix (-U)
+func dial(ctx context.Context, opts options) (net.Conn, error) {
+	if opts.unix {
+		if len(opts.args) != 1 {
+			return nil, errUsage
+		}
+		return dialUnix(ctx, opts)
+	}
+	if len(opts.args) != 2 {
+		return nil, errUsage
+	}
+	dialer, err := newDialer(opts)
+	if err != nil {
+		return nil, err
+	}
+	address := net.JoinHostPort(opts.args[0], opts.args[1])
+	if opts.proxy.address != "" {
+		if opts.udp {
+			return nil, errors.New("proxy is not supported with UDP")
+		}
+		return dialProxy(ctx, dialer, "tcp"+opts.family, opts.proxy, address)
+	}
+	return dialer.DialContext(ctx, opts.network(), address)
+}
+
+// newDialer возвращает net.Dialer с таймаутом -w и локальным адресом из -s и -p
+func newDialer(opts options) (*net.Dialer, error) {
+	dialer := &net.Dialer{Timeout: opts.timeout}
+	if opts.source == "" && opts.port == "" {
+		return dialer, nil
+	}
+	local := net.JoinHostPort(opts.source, opts.port)
+	var err error
+	// адрес прокси всегда TCP
+	if opts.udp && opts.proxy.address == "" {
+		dialer.LocalAddr, err = net.ResolveUDPAddr("udp"+opts.family, local)
+	} else {
+		dialer.LocalAddr, err = net.ResolveTCPAddr("tcp"+opts.family, local)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dialer, nil
+}
+
+// unixgramConn - датаграммный сокет Unix клиента, привязанный к временному пути,
+// чтобы получать ответы сервера. Путь удаляется при закрытии
+type unixgramConn struct {
+	*net.UnixConn
+	dir string
+}
+
+// Close закрывает сокет и удаляет временную директорию с ним
+func (c *unixgramConn) Close() error {
+	err := c.UnixConn.Close()
+	os.RemoveAll(c.dir)
+	return err
+}
+
+// dialUnix подключается к сокету Unix opts.args[0]. Датаграммный сокет привязывается к пути -s
+// или к временному пути
+func dialUnix(ctx context.Context, opts options) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: opts.timeout}
+	if !opts.udp {
+		if opts.source != "" {
+			dialer.LocalAddr = &net.UnixAddr{Name: opts.source, Net: "unix"}
+		}
+		return dialer.DialContext(ctx, "unix", opts.args[0])
+	}
+
+	if opts.source != "" {
+		dialer.LocalAddr = &net.UnixAddr{Name: opts.source, Net: "unixgram"}
+		return dialer.DialContext(ctx, "unixgram", opts.args[0])
+	}
+	dir, err := ioutil.TempDir("", "nc")
+	if err != nil {
+		return nil, err
+	}
+	dialer.LocalAddr = &net.UnixAddr{Name: filepath.Join(dir, "nc.sock"), Net: "unixgram"}
+	conn, err := dialer.DialContext(ctx, "unixgram", opts.args[0])
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &unixgramConn{UnixConn: conn.(*net.UnixConn), dir: dir}, nil
+}
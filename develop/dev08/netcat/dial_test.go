@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetwork(t *testing.T) {
+	testCases := []struct {
+		opts     options
+		expected string
+	}{
+		{options{}, "tcp"},
+		{options{udp: true}, "udp"},
+		{options{family: "4"}, "tcp4"},
+		{options{udp: true, family: "6"}, "udp6"},
+		{options{unix: true}, "unix"},
+		{options{unix: true, udp: true, family: "4"}, "unixgram"},
+	}
+
+	for _, testCase := range testCases {
+		if res := testCase.opts.network(); res != testCase.expected {
+			t.Errorf("testing %+v, expected: %s, got: %s", testCase.opts, testCase.expected, res)
+		}
+	}
+}
+
+// fileExists сообщает, существует ли файл path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestUnixStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netcat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stream.sock")
+
+	// у сервера нет данных: он сразу закрывает соединение на запись, но принимает данные клиента
+	out := &syncBuffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- run(context.Background(), options{listen: true, unix: true, quit: -1, args: []string{path}}, strings.NewReader(""), out, ioutil.Discard)
+	}()
+	waitFor(t, func() bool { return fileExists(path) })
+
+	// клиент отправляет данные и закрывает соединение на запись, после чего обе стороны завершаются
+	opts := options{unix: true, quit: -1, args: []string{path}}
+	if err := run(context.Background(), opts, strings.NewReader("hello\n"), ioutil.Discard, ioutil.Discard); err != nil {
+		t.Errorf("expected no client error, got: %s", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("expected no server error, got: %s", err)
+	}
+	if out.String() != "hello\n" {
+		t.Errorf("expected server to receive data, got: %q", out.String())
+	}
+	if fileExists(path) {
+		t.Errorf("expected socket file to be removed")
+	}
+}
+
+func TestUnixDatagram(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netcat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dgram.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	serverOut := &syncBuffer{}
+	serverIn, serverStdin := io.Pipe()
+	defer serverStdin.Close()
+	serverDone := make(chan error, 1)
+	go func() {
+		opts := options{listen: true, unix: true, udp: true, quit: -1, args: []string{path}}
+		serverDone <- run(ctx, opts, serverIn, serverOut, ioutil.Discard)
+	}()
+	waitFor(t, func() bool { return fileExists(path) })
+
+	clientOut := &syncBuffer{}
+	clientIn, clientStdin := io.Pipe()
+	defer clientStdin.Close()
+	clientDone := make(chan error, 1)
+	go func() {
+		opts := options{unix: true, udp: true, quit: -1, args: []string{path}}
+		clientDone <- run(ctx, opts, clientIn, clientOut, ioutil.Discard)
+	}()
+
+	clientStdin.Write([]byte("ping\n"))
+	waitFor(t, func() bool { return serverOut.String() == "ping\n" })
+	// ответ сервера доходит до клиента через его временный сокет
+	serverStdin.Write([]byte("pong\n"))
+	waitFor(t, func() bool { return clientOut.String() == "pong\n" })
+
+	cancel()
+	if err := <-clientDone; err != nil {
+		t.Errorf("expected no client error, got: %s", err)
+	}
+	if err := <-serverDone; err != nil {
+		t.Errorf("expected no server error, got: %s", err)
+	}
+	if fileExists(path) {
+		t.Errorf("expected socket file to be removed")
+	}
+}
+
+func TestSourceAddress(t *testing.T) {
+	remote := make(chan net.Addr, 1)
+	host, port := startServer(t, func(conn *net.TCPConn) { remote <- conn.RemoteAddr() })
+	source := freePort(t, "tcp")
+
+	opts := options{source: "127.0.0.1", port: strconv.Itoa(source), quit: -1, args: []string{host, port}}
+	if err := run(context.Background(), opts, strings.NewReader(""), ioutil.Discard, ioutil.Discard); err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	select {
+	case addr := <-remote:
+		if addr.(*net.TCPAddr).Port != source {
+			t.Errorf("expected connection from port %d, got: %s", source, addr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	opts = options{family: "6", args: []string{"127.0.0.1", port}}
+	if err := run(context.Background(), opts, strings.NewReader(""), ioutil.Discard, ioutil.Discard); err == nil {
+		t.Errorf("expected error connecting to IPv4 address with -6")
+	}
+}
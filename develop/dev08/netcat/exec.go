@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// command возвращает команду -e (программа с аргументами через пробел) или -c (команда шелла).
+// Возвращает nil, если ни одна из них не задана
+func (o *options) command(ctx context.Context) (*exec.Cmd, error) {
+	switch {
+	case o.exec != "":
+		args := strings.Fields(o.exec)
+		if len(args) == 0 {
+			return nil, errUsage
+		}
+		return exec.CommandContext(ctx, args[0], args[1:]...), nil
+	case o.shell != "":
+		return exec.CommandContext(ctx, "/bin/sh", "-c", o.shell), nil
+	}
+	return nil, nil
+}
+
+// hasCommand сообщает, что задана команда -e или -c
+func (o *options) hasCommand() bool {
+	return o.exec != "" || o.shell != ""
+}
+
+// execConn запускает команду cmd, подключенную к conn: данные из соединения поступают на stdin
+// команды, а ее stdout отправляется в соединение. stderr команды выводится в diag.
+// Завершается, когда команда закрывает stdout, и закрывает conn. Отмена ctx завершает команду
+func execConn(conn net.Conn, cmd *exec.Cmd, diag io.Writer) error {
+	defer conn.Close()
+	cmd.Stderr = diag
+	// копирование выполняется вручную: если передать conn в cmd.Stdin,
+	// Wait будет ждать конца данных из соединения и после завершения команды
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		io.Copy(stdin, conn)
+		stdin.Close()
+	}()
+	_, copyErr := io.Copy(conn, stdout)
+	// удаленная сторона получает EOF, даже если соединение закроется не сразу
+	closeWrite(conn)
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+	return copyErr
+}
+
+// serveExec принимает соединения на l до отмены ctx и запускает для каждого отдельную команду -e или -c.
+// Ошибки команд выводятся в diag при verbose
+func serveExec(ctx context.Context, l net.Listener, opts options, diag io.Writer) error {
+	var wg sync.WaitGroup
+	// команды завершаются при отмене ctx; serve дожидается их
+	defer wg.Wait()
+	defer closeOnDone(ctx, l)()
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		cmd, err := opts.command(ctx)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		if opts.verbose {
+			fmt.Fprintf(diag, "connection from %s\n", conn.RemoteAddr())
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := execConn(conn, cmd, diag); err != nil && opts.verbose && ctx.Err() == nil {
+				fmt.Fprintf(diag, "%s: %s\n", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecClient(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     options
+		expected string
+		err      string // подстрока ошибки; пустая - без ошибки
+	}{
+		{"exec", options{exec: "tr a-z A-Z"}, "HELLO\n", ""},
+		{"shell", options{shell: "read x; echo \"got $x\"; echo err >&2"}, "got hello\n", ""},
+		{"exit status", options{shell: "cat; exit 3"}, "hello\n", "exit status 3"},
+		{"not found", options{exec: "/nonexistent/prog"}, "", "no such file"},
+		{"both", options{exec: "cat", shell: "cat"}, "", "mutually exclusive"},
+	}
+
+	for _, testCase := range testCases {
+		received := make(chan string, 1)
+		host, port := startServer(t, func(conn *net.TCPConn) {
+			conn.Write([]byte("hello\n"))
+			conn.CloseWrite()
+			res, _ := ioutil.ReadAll(conn)
+			received <- string(res)
+		})
+		opts := testCase.opts
+		opts.args = []string{host, port}
+		err := run(context.Background(), opts, nil, ioutil.Discard, ioutil.Discard)
+		if testCase.err == "" && err != nil || testCase.err != "" && (err == nil || !strings.Contains(err.Error(), testCase.err)) {
+			t.Errorf("testing %s, expected error with %q, got: %v", testCase.name, testCase.err, err)
+		}
+		if testCase.expected == "" {
+			continue
+		}
+		select {
+		case res := <-received:
+			if res != testCase.expected {
+				t.Errorf("testing %s, expected: %q, got: %q", testCase.name, testCase.expected, res)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("testing %s: timeout", testCase.name)
+		}
+	}
+}
+
+func TestExecListen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netcat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "exec.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		opts := options{listen: true, keep: true, unix: true, shell: `while read x; do echo "got $x"; done`, args: []string{path}}
+		done <- run(ctx, opts, nil, ioutil.Discard, ioutil.Discard)
+	}()
+	waitFor(t, func() bool { return fileExists(path) })
+
+	// каждое соединение обслуживает отдельная команда, поэтому они не мешают друг другу
+	a, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("expected no error on dial, got: %s", err)
+	}
+	defer a.Close()
+	b, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("expected no error on dial, got: %s", err)
+	}
+	b.Write([]byte("b\n"))
+	if res := readFull(t, b, 6); res != "got b\n" {
+		t.Errorf("expected reply on second connection, got: %q", res)
+	}
+	// команда завершается по концу данных из соединения и закрывает его
+	b.(*net.UnixConn).CloseWrite()
+	b.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if rest, err := ioutil.ReadAll(b); err != nil || len(rest) != 0 {
+		t.Errorf("expected connection to be closed after command exit, got: %q (%v)", rest, err)
+	}
+	b.Close()
+
+	a.Write([]byte("a\n"))
+	if res := readFull(t, a, 6); res != "got a\n" {
+		t.Errorf("expected reply on first connection, got: %q", res)
+	}
+
+	// отмена завершает оставшиеся команды
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected no error after cancel, got: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for server")
+	}
+	a.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := a.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected EOF after cancel, got: %v", err)
+	}
+}
+
+func TestExecOptions(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts options
+		err  string
+	}{
+		{"broker", options{listen: true, broker: true, exec: "cat", args: []string{"0"}}, "--broker"},
+		{"udp listen", options{listen: true, udp: true, exec: "cat", args: []string{"0"}}, "datagram"},
+	}
+
+	for _, testCase := range testCases {
+		err := run(context.Background(), testCase.opts, nil, ioutil.Discard, ioutil.Discard)
+		if err == nil || !strings.Contains(err.Error(), testCase.err) {
+			t.Errorf("testing %s, expected error with %q, got: %v", testCase.name, testCase.err, err)
+		}
+	}
+
+	host, port := startServer(t, func(conn *net.TCPConn) {})
+	if err := run(context.Background(), options{exec: " ", args: []string{host, port}}, nil, ioutil.Discard, ioutil.Discard); err != errUsage {
+		t.Errorf("expected usage error for empty program, got: %v", err)
+	}
+}
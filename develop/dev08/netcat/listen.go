@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// bufferSize - размер буфера для чтения из соединений и stdin
+const bufferSize = 32 * 1024
+
+// listenAddress возвращает адрес для прослушивания из аргументов командной строки
+// и значения флага -p: "nc -l [HOST] PORT" или "nc -l -p PORT [HOST]"
+func listenAddress(args []string, port string) (string, error) {
+	host := ""
+	switch {
+	case port == "" && len(args) == 1:
+		port = args[0]
+	case port == "" && len(args) == 2:
+		host, port = args[0], args[1]
+	case port != "" && len(args) == 1:
+		host = args[0]
+	case port != "" && len(args) == 0:
+	default:
+		return "", errors.New("invalid listen address")
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// runListen выполняет netcat в режиме сервера
+func runListen(ctx context.Context, opts options, in io.Reader, out, diag io.Writer) error {
+	address, err := listenAddress(opts.args, opts.port)
+	if opts.unix {
+		if len(opts.args) != 1 {
+			return errUsage
+		}
+		address, err = opts.args[0], nil
+	} else if host, port, _ := net.SplitHostPort(address); err == nil && host == "" && opts.source != "" {
+		address = net.JoinHostPort(opts.source, port)
+	}
+	if err != nil {
+		return errUsage
+	}
+	if opts.broker && opts.hasCommand() {
+		return errors.New("--broker cannot be used with -e or -c")
+	}
+	if opts.udp {
+		if opts.tls.enabled {
+			return errors.New("--ssl is not supported with datagram sockets")
+		}
+		if opts.hasCommand() {
+			return errors.New("-e and -c are not supported with datagram sockets in listen mode")
+		}
+		pc, err := net.ListenPacket(opts.network(), address)
+		if err != nil {
+			return err
+		}
+		if opts.unix {
+			// в отличие от потоковых сокетов, файл датаграммного сокета не удаляется при закрытии
+			defer os.Remove(address)
+		}
+		if opts.verbose {
+			fmt.Fprintf(diag, "listening on %s\n", pc.LocalAddr())
+		}
+		return serveUDP(ctx, pc, opts.broker, in, out)
+	}
+	l, err := net.Listen(opts.network(), address)
+	if err != nil {
+		return err
+	}
+	if opts.verbose {
+		fmt.Fprintf(diag, "listening on %s\n", l.Addr())
+	}
+	if opts.tls.enabled {
+		host, _, _ := net.SplitHostPort(address)
+		config, err := serverTLSConfig(opts.tls, host, opts.verbose, diag)
+		if err != nil {
+			l.Close()
+			return err
+		}
+		l = tls.NewListener(l, config)
+	}
+	if opts.keep && opts.hasCommand() {
+		return serveExec(ctx, l, opts, diag)
+	}
+	if opts.keep || opts.broker {
+		return newHub(opts.broker, out).serve(ctx, l, in)
+	}
+	conn, err := acceptOne(ctx, l)
+	if err != nil || conn == nil {
+		return err
+	}
+	if opts.verbose {
+		fmt.Fprintf(diag, "connection from %s\n", conn.RemoteAddr())
+	}
+	if err := handshake(ctx, conn, opts.verbose, diag); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	if opts.hasCommand() {
+		return runCommand(ctx, conn, opts, diag)
+	}
+	return transfer(ctx, conn, in, out, opts.quit)
+}
+
+// acceptOne принимает одно соединение и закрывает l: дальше обмен данными идет как в режиме клиента.
+// При отмене ctx возвращает nil без ошибки
+func acceptOne(ctx context.Context, l net.Listener) (net.Conn, error) {
+	stop := closeOnDone(ctx, l)
+	defer stop()
+	defer l.Close()
+	conn, err := l.Accept()
+	if ctx.Err() != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, nil
+	}
+	return conn, err
+}
+
+// closeOnDone закрывает c при отмене ctx, прерывая ожидающие операции.
+// Вызов возвращаемой функции прекращает ожидание отмены
+func closeOnDone(ctx context.Context, c io.Closer) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// clientQueueSize - количество блоков данных, ожидающих отправки клиенту hub
+const clientQueueSize = 64
+
+// clientTimeout - время, в течение которого клиент hub может не принимать данные, прежде чем будет отключен
+const clientTimeout = 10 * time.Second
+
+// hub - сервер, принимающий соединения в режиме -k: данные из stdin рассылаются всем клиентам,
+// данные клиентов выводятся в out, а в режиме broker еще и пересылаются остальным клиентам
+type hub struct {
+	mu      sync.Mutex
+	clients map[net.Conn]*hubClient
+	broker  bool
+	timeout time.Duration // время ожидания места в очереди клиента
+	outMu   sync.Mutex
+	out     io.Writer
+}
+
+// hubClient - клиент hub. Данные отправляются клиенту отдельной горутиной из очереди queue,
+// поэтому запись в один сокет не задерживает остальных клиентов
+type hubClient struct {
+	conn  net.Conn
+	queue chan []byte
+	done  chan struct{} // закрывается при отключении клиента
+}
+
+// newHub - конструктор hub
+func newHub(broker bool, out io.Writer) *hub {
+	return &hub{
+		clients: make(map[net.Conn]*hubClient),
+		broker:  broker,
+		timeout: clientTimeout,
+		out:     out,
+	}
+}
+
+// serve принимает соединения на l до отмены ctx и рассылает клиентам данные из in
+func (h *hub) serve(ctx context.Context, l net.Listener, in io.Reader) error {
+	defer closeOnDone(ctx, l)()
+	defer l.Close()
+	go h.broadcast(in)
+	defer h.closeAll()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go h.handle(ctx, conn)
+	}
+}
+
+// broadcast рассылает данные из in всем подключенным клиентам до конца ввода
+func (h *hub) broadcast(in io.Reader) {
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			h.relay(buf[:n], nil)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handle добавляет conn в список клиентов и читает его данные до закрытия соединения.
+// Соединения TLS добавляются после согласования, чтобы рассылка не ждала медленных клиентов
+func (h *hub) handle(ctx context.Context, conn net.Conn) {
+	if hs, ok := conn.(handshaker); ok {
+		if err := hs.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return
+		}
+	}
+	client := &hubClient{conn: conn, queue: make(chan []byte, clientQueueSize), done: make(chan struct{})}
+	go client.write()
+	h.mu.Lock()
+	h.clients[conn] = client
+	h.mu.Unlock()
+	defer h.remove(conn)
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			h.outMu.Lock()
+			h.out.Write(buf[:n])
+			h.outMu.Unlock()
+			if h.broker {
+				h.relay(buf[:n], conn)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// relay ставит копию data в очереди всех клиентов, кроме from. Если очередь клиента остается
+// заполненной дольше h.timeout (клиент не читает данные), клиент отключается
+func (h *hub) relay(data []byte, from net.Conn) {
+	data = append([]byte(nil), data...)
+	// очереди заполняются вне h.mu, чтобы ожидание места не блокировало подключение клиентов
+	h.mu.Lock()
+	clients := make([]*hubClient, 0, len(h.clients))
+	for conn, client := range h.clients {
+		if conn != from {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.Unlock()
+
+	var timer *time.Timer
+	for _, client := range clients {
+		select {
+		case client.queue <- data:
+			continue
+		case <-client.done:
+			continue
+		default:
+		}
+		if timer == nil {
+			timer = time.NewTimer(h.timeout)
+			defer timer.Stop()
+		}
+		select {
+		case client.queue <- data:
+		case <-client.done:
+		case <-timer.C:
+			client.conn.Close()
+			// остальные клиенты ждали вместе с этим, поэтому следующему дается новый срок
+			timer = nil
+		}
+	}
+}
+
+// write отправляет клиенту данные из очереди до его отключения. При ошибке записи закрывает соединение
+func (c *hubClient) write() {
+	for {
+		select {
+		case data := <-c.queue:
+			if _, err := c.conn.Write(data); err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// remove закрывает соединение conn и удаляет его из списка клиентов
+func (h *hub) remove(conn net.Conn) {
+	h.mu.Lock()
+	if client, ok := h.clients[conn]; ok {
+		close(client.done)
+		delete(h.clients, conn)
+	}
+	h.mu.Unlock()
+	conn.Close()
+}
+
+// closeAll закрывает соединения со всеми клиентами
+func (h *hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for conn := range h.clients {
+		conn.Close()
+	}
+}
+
+// serveUDP принимает датаграммы на pc и выводит их в out. Данные из in отправляются
+// последнему приславшему датаграмму узлу, а в режиме broker - всем известным узлам,
+// которым также пересылаются датаграммы остальных узлов. Работает до отмены ctx
+func serveUDP(ctx context.Context, pc net.PacketConn, broker bool, in io.Reader, out io.Writer) error {
+	defer closeOnDone(ctx, pc)()
+	defer pc.Close()
+	var mu sync.Mutex
+	var last net.Addr
+	peers := make(map[string]net.Addr)
+
+	// relay отправляет data узлам, кроме from. Вызывается под mu
+	relay := func(data []byte, from net.Addr) {
+		if !broker {
+			if last != nil && from == nil {
+				pc.WriteTo(data, last)
+			}
+			return
+		}
+		for key, addr := range peers {
+			if from == nil || key != from.String() {
+				pc.WriteTo(data, addr)
+			}
+		}
+	}
+
+	go func() {
+		buf := make([]byte, bufferSize)
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				mu.Lock()
+				relay(buf[:n], nil)
+				mu.Unlock()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		mu.Lock()
+		last = addr
+		peers[addr.String()] = addr
+		out.Write(buf[:n])
+		relay(buf[:n], addr)
+		mu.Unlock()
+	}
+}
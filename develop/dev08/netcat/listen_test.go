@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer - bytes.Buffer, безопасный для конкурентного использования
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// readFull читает из conn ровно n байт с таймаутом
+func readFull(t *testing.T, conn net.Conn, n int) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("expected no error on read, got: %s", err)
+	}
+	return string(buf)
+}
+
+// waitFor ждет, пока cond не станет истинным
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timeout")
+}
+
+func TestListenAddress(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		port     string
+		expected string
+		err      bool
+	}{
+		{[]string{"1234"}, "", ":1234", false},
+		{[]string{"localhost", "1234"}, "", "localhost:1234", false},
+		{nil, "1234", ":1234", false},
+		{[]string{"::1"}, "1234", "[::1]:1234", false},
+		{nil, "", "", true},
+		{[]string{"a", "b"}, "1234", "", true},
+	}
+
+	for _, testCase := range testCases {
+		res, err := listenAddress(testCase.args, testCase.port)
+		if (err != nil) != testCase.err || res != testCase.expected {
+			t.Errorf("testing %v -p %q, expected: %q (error %v), got: %q (%v)", testCase.args, testCase.port, testCase.expected, testCase.err, res, err)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	for _, broker := range []bool{false, true} {
+		l, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatalf("expected no error on listen, got: %s", err)
+		}
+		out := &syncBuffer{}
+		in, stdin := io.Pipe()
+		h := newHub(broker, out)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- h.serve(ctx, l, in) }()
+
+		a, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("expected no error on dial, got: %s", err)
+		}
+		b, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("expected no error on dial, got: %s", err)
+		}
+		waitFor(t, func() bool {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			return len(h.clients) == 2
+		})
+
+		// данные из stdin получают все клиенты
+		stdin.Write([]byte("all\n"))
+		if res := readFull(t, a, 4); res != "all\n" {
+			t.Errorf("broker %v: expected stdin data on first client, got: %q", broker, res)
+		}
+		if res := readFull(t, b, 4); res != "all\n" {
+			t.Errorf("broker %v: expected stdin data on second client, got: %q", broker, res)
+		}
+
+		a.Write([]byte("from a\n"))
+		waitFor(t, func() bool { return out.String() == "from a\n" })
+		if broker {
+			if res := readFull(t, b, 7); res != "from a\n" {
+				t.Errorf("expected relayed data, got: %q", res)
+			}
+		} else {
+			b.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			if n, _ := b.Read(make([]byte, 1)); n != 0 {
+				t.Errorf("expected no relayed data without broker")
+			}
+		}
+
+		a.Close()
+		b.Close()
+		stdin.Close()
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("expected no error after cancel, got: %s", err)
+		}
+	}
+}
+
+func TestHubSlowClient(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	in, stdin := io.Pipe()
+	h := newHub(false, ioutil.Discard)
+	h.timeout = 200 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.serve(ctx, l, in)
+
+	// первый клиент не читает данные
+	stalled, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("expected no error on dial, got: %s", err)
+	}
+	defer stalled.Close()
+	reader, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("expected no error on dial, got: %s", err)
+	}
+	defer reader.Close()
+	waitFor(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.clients) == 2
+	})
+
+	// объем данных больше буферов сокета и очереди клиента
+	const size = 32 << 20
+	go func() {
+		chunk := make([]byte, bufferSize)
+		for sent := 0; sent < size; sent += len(chunk) {
+			stdin.Write(chunk)
+		}
+		stdin.Close()
+	}()
+	reader.SetReadDeadline(time.Now().Add(20 * time.Second))
+	n, err := io.CopyN(ioutil.Discard, reader, size)
+	if err != nil || n != size {
+		t.Errorf("expected %d bytes despite stalled client, got: %d (%v)", size, n, err)
+	}
+}
+
+func TestServeUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	out := &syncBuffer{}
+	in, stdin := io.Pipe()
+	defer stdin.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- serveUDP(ctx, pc, false, in, out) }()
+
+	a, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("expected no error on dial, got: %s", err)
+	}
+	defer a.Close()
+	b, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("expected no error on dial, got: %s", err)
+	}
+	defer b.Close()
+
+	a.Write([]byte("a\n"))
+	waitFor(t, func() bool { return out.String() == "a\n" })
+	b.Write([]byte("b\n"))
+	waitFor(t, func() bool { return out.String() == "a\nb\n" })
+
+	// ответ получает последний приславший датаграмму узел
+	stdin.Write([]byte("reply\n"))
+	if res := readFull(t, b, 6); res != "reply\n" {
+		t.Errorf("expected reply to most recent peer, got: %q", res)
+	}
+	a.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, _ := a.Read(make([]byte, 16)); n != 0 {
+		t.Errorf("expected no reply to previous peer")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected no error after cancel, got: %s", err)
+	}
+}
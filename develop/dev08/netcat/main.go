@@ -1,74 +1,185 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
-func main() {
-	udp := flag.Bool("u", false, "Use UDP (default is TCP)")
-	
-	flag.Parse()
+const usage = `Usage: nc [OPTIONS] HOST PORT
+       nc -l [OPTIONS] [HOST] PORT
+       nc -l [OPTIONS] -p PORT [HOST]
+       nc -z [OPTIONS] HOST PORTS...
+       nc -U [OPTIONS] [-l] PATH`
 
-	protocol := "tcp"
-	if *udp {
-		protocol = "udp"
-	}
+// errUsage - ошибка в аргументах командной строки
+var errUsage = errors.New("invalid arguments")
 
-	if len(flag.Args()) != 2 {
-		fmt.Println("Usage: nc [OPTIONS] HOST PORT")
-		os.Exit(1)
+// options - параметры запуска netcat
+type options struct {
+	udp     bool          // UDP вместо TCP; с -U - датаграммные сокеты Unix
+	unix    bool          // сокеты Unix вместо TCP/UDP
+	family  string        // семейство адресов: "4", "6" или "" - любое
+	listen  bool          // режим сервера
+	port    string        // порт для прослушивания или локальный порт подключения (-p)
+	source  string        // локальный адрес подключения или адрес для прослушивания (-s)
+	proxy   proxyOptions  // параметры прокси
+	keep    bool          // принимать соединения после первого
+	broker  bool          // пересылать данные между клиентами
+	quit    time.Duration // время ожидания после конца ввода; отрицательное - ждать закрытия соединения удаленной стороной
+	scan    bool          // сканирование портов без передачи данных
+	timeout time.Duration // таймаут подключения (-w)
+	verbose bool          // подробный вывод в stderr
+	tls     tlsOptions    // параметры TLS
+	exec    string        // программа, подключаемая к соединению (-e)
+	shell   string        // команда шелла, подключаемая к соединению (-c)
+	args    []string      // позиционные аргументы
+}
+
+// network возвращает сеть для net.Dial и net.Listen
+func (o *options) network() string {
+	switch {
+	case o.unix && o.udp:
+		return "unixgram"
+	case o.unix:
+		return "unix"
+	case o.udp:
+		return "udp" + o.family
 	}
-	host := flag.Args()[0]
-	port := flag.Args()[1]
+	return "tcp" + o.family
+}
 
-	conn, err := net.Dial(protocol, net.JoinHostPort(host, port))
-	if err != nil {
-		log.Fatal(err)
+func main() {
+	opts := options{}
+	flag.BoolVar(&opts.udp, "u", false, "Use UDP (default is TCP)")
+	flag.BoolVar(&opts.unix, "U", false, "Use Unix domain sockets (datagram with -u)")
+	ipv4 := flag.Bool("4", false, "Use IPv4 addresses only")
+	ipv6 := flag.Bool("6", false, "Use IPv6 addresses only")
+	flag.BoolVar(&opts.listen, "l", false, "Listen for incoming connections")
+	flag.StringVar(&opts.port, "p", "", "Port to listen on, or local port to connect from")
+	flag.StringVar(&opts.source, "s", "", "Local address to connect from or to listen on")
+	flag.StringVar(&opts.proxy.kind, "X", "", "Proxy protocol: connect (HTTP CONNECT) or 5 (SOCKS5, default)")
+	flag.StringVar(&opts.proxy.address, "x", "", "Proxy address as HOST[:PORT]")
+	flag.BoolVar(&opts.keep, "k", false, "Keep accepting connections after the first one (with -l)")
+	flag.BoolVar(&opts.broker, "broker", false, "Relay data between all connected clients (implies -l -k)")
+	quit := flag.Int("q", -1, "Quit SECS seconds after EOF on stdin (negative waits for the remote side to close)")
+	flag.BoolVar(&opts.scan, "z", false, "Scan PORTS (e.g. 20-25,80,443) without sending data")
+	timeout := flag.Int("w", 0, "Connect timeout in SECS")
+	flag.BoolVar(&opts.verbose, "v", false, "Verbose output to stderr")
+	flag.BoolVar(&opts.tls.enabled, "ssl", false, "Connect or listen with TLS")
+	flag.StringVar(&opts.tls.cert, "ssl-cert", "", "Certificate file (PEM); without it listen mode generates a self-signed one")
+	flag.StringVar(&opts.tls.key, "ssl-key", "", "Private key file (PEM) for --ssl-cert")
+	flag.StringVar(&opts.tls.trustFile, "ssl-trustfile", "", "CA certificates file (PEM) to verify the peer")
+	flag.BoolVar(&opts.tls.verify, "ssl-verify", false, "Verify the server certificate (default); in listen mode require a client certificate")
+	flag.BoolVar(&opts.tls.insecure, "insecure", false, "Do not verify the server certificate")
+	flag.StringVar(&opts.tls.serverName, "ssl-servername", "", "Server name for SNI and certificate verification instead of HOST")
+	flag.StringVar(&opts.exec, "e", "", "Execute PROG with its stdin and stdout connected to the socket (one per connection with -k)")
+	flag.StringVar(&opts.shell, "c", "", "Like -e, but run the command with /bin/sh -c")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
 	}
 
-	signalChan := make(chan os.Signal, 1)
-	errorChan := make(chan error, 1)
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	flag.Parse()
+	opts.quit = time.Duration(*quit) * time.Second
+	opts.timeout = time.Duration(*timeout) * time.Second
+	opts.args = flag.Args()
+	switch {
+	case *ipv4 && *ipv6:
+		fmt.Fprintln(os.Stderr, "-4 and -6 are mutually exclusive")
+		os.Exit(1)
+	case *ipv4:
+		opts.family = "4"
+	case *ipv6:
+		opts.family = "6"
+	}
 
-	go send(conn, errorChan)
-	go recv(conn, errorChan)
+	// SIGINT и SIGTERM отменяют контекст: соединения закрываются, и netcat завершается с кодом 0
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	select {
-	case <-signalChan:
-		conn.Close()
-	case <-errorChan:
+	err := run(ctx, opts, os.Stdin, os.Stdout, os.Stderr)
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	if errors.Is(err, errNoOpenPorts) {
+		os.Exit(1)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
-
 }
 
-// send бесконечно считывает текси из stdin и отправляет в conn
-func send(conn net.Conn, errorChan chan<- error) {
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		text, err := reader.ReadString('\n')
+// run выполняет netcat с параметрами opts: данные из in отправляются в соединение,
+// данные из соединения выводятся в out, подробный вывод (-v) - в diag. Возвращает nil
+// при штатном завершении (конец данных или отмена ctx)
+func run(ctx context.Context, opts options, in io.Reader, out, diag io.Writer) error {
+	if opts.exec != "" && opts.shell != "" {
+		return errors.New("-e and -c are mutually exclusive")
+	}
+	if opts.scan {
+		return runScan(ctx, opts, diag)
+	}
+	if opts.listen || opts.broker {
+		return runListen(ctx, opts, in, out, diag)
+	}
+
+	conn, err := dial(ctx, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	if opts.verbose {
+		fmt.Fprintf(diag, "connected to %s\n", conn.RemoteAddr())
+	}
+	if opts.tls.enabled {
+		host := opts.args[0]
+		if opts.unix {
+			host = ""
+		}
+		config, err := clientTLSConfig(opts.tls, host)
 		if err != nil {
-			errorChan <- err
+			conn.Close()
+			return err
+		}
+		conn = tls.Client(conn, config)
+		if err := handshake(ctx, conn, opts.verbose, diag); err != nil {
+			conn.Close()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
 		}
-		fmt.Fprintf(conn, text + "\n")
 	}
+	if opts.hasCommand() {
+		return runCommand(ctx, conn, opts, diag)
+	}
+	return transfer(ctx, conn, in, out, opts.quit)
 }
 
-// recv бесконечно считывает текст из conn и выводит в stdout
-func recv(conn net.Conn, errorChan chan<- error) {
-	reader := bufio.NewReader(conn)
-	for {
-		text, err := reader.ReadString('\n')
-		if err != nil {
-			errorChan <- err
-		}
-		fmt.Print(text)
+// runCommand подключает к conn команду -e или -c и ждет ее завершения
+func runCommand(ctx context.Context, conn net.Conn, opts options, diag io.Writer) error {
+	cmd, err := opts.command(ctx)
+	if err != nil {
+		conn.Close()
+		return err
 	}
-}
\ No newline at end of file
+	err = execConn(conn, cmd, diag)
+	// команда, завершенная из-за отмены, не является ошибкой
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
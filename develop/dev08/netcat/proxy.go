@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// proxyOptions - параметры прокси (-X, -x)
+type proxyOptions struct {
+	kind    string // протокол: "connect" или "5"
+	address string // адрес прокси HOST[:PORT]
+}
+
+// порты прокси по умолчанию
+const (
+	defaultConnectPort = "3128"
+	defaultSOCKSPort   = "1080"
+)
+
+// maxProxyHeader - максимальный размер заголовка ответа прокси HTTP
+const maxProxyHeader = 8 * 1024
+
+// socksErrors - описания кодов ошибок SOCKS5 (RFC 1928)
+var socksErrors = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// dialProxy подключается к address через прокси proxy. network - сеть для подключения к прокси
+func dialProxy(ctx context.Context, dialer *net.Dialer, network string, proxy proxyOptions, address string) (net.Conn, error) {
+	var handshake func(conn net.Conn, address string) error
+	port := defaultSOCKSPort
+	switch proxy.kind {
+	case "connect":
+		handshake, port = connectHandshake, defaultConnectPort
+	case "5", "":
+		handshake = socksHandshake
+	default:
+		return nil, fmt.Errorf("%s: unknown proxy protocol", proxy.kind)
+	}
+	proxyAddress := proxy.address
+	if _, _, err := net.SplitHostPort(proxyAddress); err != nil {
+		proxyAddress = net.JoinHostPort(proxyAddress, port)
+	}
+
+	conn, err := dialer.DialContext(ctx, network, proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	// отмена прерывает согласование с прокси
+	stop := closeOnDone(ctx, conn)
+	err = handshake(conn, address)
+	stop()
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("proxy %s: %w", proxyAddress, err)
+	}
+	return conn, nil
+}
+
+// connectHandshake запрашивает у прокси HTTP туннель к address методом CONNECT
+func connectHandshake(conn net.Conn, address string) error {
+	if _, err := fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", address, address); err != nil {
+		return err
+	}
+	// заголовок читается побайтно: данные туннеля после него не должны попасть в буфер
+	header := make([]byte, 0, 512)
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(header, []byte("\r\n\r\n")) {
+		if len(header) >= maxProxyHeader {
+			return errors.New("response header too long")
+		}
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return err
+		}
+		header = append(header, b[0])
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(header)), nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CONNECT %s: %s", address, resp.Status)
+	}
+	return nil
+}
+
+// socksHandshake запрашивает у прокси SOCKS5 подключение к address без аутентификации.
+// Имена узлов передаются прокси без разрешения
+func socksHandshake(conn net.Conn, address string) error {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return fmt.Errorf("%s: invalid port", portString)
+	}
+
+	// приветствие: версия 5, один метод - без аутентификации
+	if _, err := conn.Write([]byte{5, 1, 0}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 5 || reply[1] != 0 {
+		return errors.New("SOCKS5 authentication required")
+	}
+
+	// запрос: версия, CONNECT, зарезервированный байт, адрес, порт
+	request := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("%s: host name too long", host)
+		}
+		request = append(request, 3, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, 1)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, 4)
+		request = append(request, ip.To16()...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	// ответ: версия, код, зарезервированный байт, тип адреса, адрес, порт
+	reply = make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 5 {
+		return errors.New("invalid SOCKS5 reply")
+	}
+	if reply[1] != 0 {
+		if msg, ok := socksErrors[reply[1]]; ok {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("SOCKS5 error %d", reply[1])
+	}
+	var size int
+	switch reply[3] {
+	case 1:
+		size = net.IPv4len
+	case 4:
+		size = net.IPv6len
+	case 3:
+		b := make([]byte, 1)
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return err
+		}
+		size = int(b[0])
+	default:
+		return errors.New("invalid SOCKS5 reply")
+	}
+	// адрес и порт, привязанные прокси, не используются
+	_, err = io.ReadFull(conn, make([]byte, size+2))
+	return err
+}
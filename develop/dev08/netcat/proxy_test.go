@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pipeConns передает данные между a и b в обе стороны с половинным закрытием и закрывает их
+func pipeConns(a, b net.Conn, fromA io.Reader) {
+	done := make(chan struct{})
+	go func() {
+		io.Copy(b, fromA)
+		closeWrite(b)
+		close(done)
+	}()
+	io.Copy(a, b)
+	closeWrite(a)
+	<-done
+	a.Close()
+	b.Close()
+}
+
+// startProxy запускает прокси-сервер, обрабатывающий соединения функцией handle, и возвращает его адрес
+func startProxy(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String()
+}
+
+// connectProxy - прокси HTTP CONNECT, отвечающий кодом status
+func connectProxy(status int, requests chan<- string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		req, err := http.ReadRequest(reader)
+		if err != nil || req.Method != http.MethodConnect {
+			conn.Close()
+			return
+		}
+		requests <- req.RequestURI
+		if status != http.StatusOK {
+			conn.Write([]byte("HTTP/1.1 " + strconv.Itoa(status) + " " + http.StatusText(status) + "\r\n\r\n"))
+			conn.Close()
+			return
+		}
+		target, err := net.Dial("tcp", req.RequestURI)
+		if err != nil {
+			conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			conn.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		pipeConns(conn, target, reader)
+	}
+}
+
+// socksProxy - прокси SOCKS5, отвечающий кодом rep
+func socksProxy(rep byte, requests chan<- string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil || header[0] != 5 {
+			conn.Close()
+			return
+		}
+		io.ReadFull(conn, make([]byte, header[1]))
+		conn.Write([]byte{5, 0})
+
+		request := make([]byte, 4)
+		if _, err := io.ReadFull(conn, request); err != nil || request[1] != 1 {
+			conn.Close()
+			return
+		}
+		var host string
+		switch request[3] {
+		case 1, 4:
+			ip := make([]byte, net.IPv4len)
+			if request[3] == 4 {
+				ip = make([]byte, net.IPv6len)
+			}
+			io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 3:
+			size := make([]byte, 1)
+			io.ReadFull(conn, size)
+			name := make([]byte, size[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		}
+		port := make([]byte, 2)
+		io.ReadFull(conn, port)
+		address := net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+		requests <- address
+
+		reply := []byte{5, rep, 0, 1, 0, 0, 0, 0, 0, 0}
+		if rep != 0 {
+			conn.Write(reply)
+			conn.Close()
+			return
+		}
+		target, err := net.Dial("tcp", address)
+		if err != nil {
+			reply[1] = 5
+			conn.Write(reply)
+			conn.Close()
+			return
+		}
+		conn.Write(reply)
+		pipeConns(conn, target, conn)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	requests := make(chan string, 1)
+	connectOK := startProxy(t, connectProxy(http.StatusOK, requests))
+	connectDenied := startProxy(t, connectProxy(http.StatusForbidden, requests))
+	socksOK := startProxy(t, socksProxy(0, requests))
+	socksRefused := startProxy(t, socksProxy(5, requests))
+
+	echo := func(conn *net.TCPConn) { io.Copy(conn, conn) }
+
+	testCases := []struct {
+		name    string
+		host    string
+		proxy   proxyOptions
+		udp     bool
+		request bool   // прокси должен получить запрос
+		err     string // подстрока ошибки; пустая - без ошибки
+	}{
+		{"connect", "127.0.0.1", proxyOptions{"connect", connectOK}, false, true, ""},
+		{"connect denied", "127.0.0.1", proxyOptions{"connect", connectDenied}, false, true, "403 Forbidden"},
+		{"socks", "127.0.0.1", proxyOptions{"5", socksOK}, false, true, ""},
+		{"socks host name", "localhost", proxyOptions{"", socksOK}, false, true, ""},
+		{"socks refused", "127.0.0.1", proxyOptions{"5", socksRefused}, false, true, "connection refused"},
+		{"unknown protocol", "127.0.0.1", proxyOptions{"4", socksOK}, false, false, "unknown proxy protocol"},
+		{"udp", "127.0.0.1", proxyOptions{"5", socksOK}, true, false, "not supported"},
+	}
+
+	for _, testCase := range testCases {
+		_, port := startServer(t, echo)
+		out := &bytes.Buffer{}
+		opts := options{udp: testCase.udp, proxy: testCase.proxy, quit: -1, args: []string{testCase.host, port}}
+		err := run(context.Background(), opts, strings.NewReader("ping\n"), out, ioutil.Discard)
+		if testCase.err == "" && (err != nil || out.String() != "ping\n") {
+			t.Errorf("testing %s, expected echo, got: %q (%v)", testCase.name, out.String(), err)
+		}
+		if testCase.err != "" && (err == nil || !strings.Contains(err.Error(), testCase.err)) {
+			t.Errorf("testing %s, expected error with %q, got: %v", testCase.name, testCase.err, err)
+		}
+		if testCase.request {
+			// имя узла передается прокси без разрешения
+			expected := net.JoinHostPort(testCase.host, port)
+			if req := <-requests; req != expected {
+				t.Errorf("testing %s, expected request for %s, got: %s", testCase.name, expected, req)
+			}
+		}
+	}
+}
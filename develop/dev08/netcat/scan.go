@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// scanWorkers - максимальное количество одновременных подключений при сканировании
+const scanWorkers = 64
+
+// defaultScanTimeout - таймаут подключения при сканировании, если не задан -w
+const defaultScanTimeout = 3 * time.Second
+
+// errNoOpenPorts - при сканировании не найдено ни одного открытого порта
+var errNoOpenPorts = errors.New("no open ports")
+
+// состояния порта при сканировании
+const (
+	portOpen         = "open"
+	portClosed       = "closed"
+	portFiltered     = "filtered"      // нет ответа (TCP) или узел недоступен
+	portOpenFiltered = "open|filtered" // UDP: нет ответа - порт открыт или пакеты отбрасываются
+)
+
+// scanResult - результат проверки одного порта
+type scanResult struct {
+	port  int
+	state string
+	err   error
+}
+
+// parsePorts разбирает список портов и диапазонов через запятую ("20-25,80,http")
+func parsePorts(spec string) ([]int, error) {
+	var ports []int
+	for _, item := range strings.Split(spec, ",") {
+		if item == "" {
+			return nil, fmt.Errorf("%q: invalid port list", spec)
+		}
+		if i := strings.IndexByte(item, '-'); i >= 0 {
+			low, err1 := parsePort(item[:i])
+			high, err2 := parsePort(item[i+1:])
+			if err1 != nil || err2 != nil || low > high {
+				return nil, fmt.Errorf("%q: invalid port range", item)
+			}
+			for port := low; port <= high; port++ {
+				ports = append(ports, port)
+			}
+			continue
+		}
+		port, err := parsePort(item)
+		if _, numErr := strconv.Atoi(item); err != nil && numErr != nil {
+			// имя сервиса из /etc/services
+			port, err = net.LookupPort("tcp", item)
+		}
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("%q: invalid port", item)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// parsePort разбирает номер порта
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%q: invalid port", s)
+	}
+	return port, nil
+}
+
+// runScan выполняет сканирование портов (-z): nc -z [-v] [-w SECS] HOST PORTS...
+// Отчет о каждом порте выводится в diag при -v. Возвращает errNoOpenPorts, если открытых портов нет
+func runScan(ctx context.Context, opts options, diag io.Writer) error {
+	if opts.unix {
+		return errors.New("-z is not supported with Unix domain sockets")
+	}
+	if len(opts.args) < 2 {
+		return errUsage
+	}
+	host := opts.args[0]
+	ports, err := parsePorts(strings.Join(opts.args[1:], ","))
+	if err != nil {
+		return err
+	}
+	// адреса определяются один раз, а не при каждом подключении
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip"+opts.family, host)
+	if err != nil {
+		return err
+	}
+	timeout := opts.timeout
+	if timeout <= 0 {
+		timeout = defaultScanTimeout
+	}
+
+	hosts := make([]string, len(addrs))
+	for i, addr := range addrs {
+		hosts[i] = addr.String()
+	}
+
+	results := scan(ctx, opts.network(), hosts, ports, timeout)
+	if ctx.Err() != nil {
+		return nil
+	}
+	found := false
+	for _, res := range results {
+		if res.state == portOpen || res.state == portOpenFiltered {
+			found = true
+		}
+		if opts.verbose {
+			fmt.Fprintf(diag, "%s %d/%s %s", host, res.port, opts.network(), res.state)
+			if res.err != nil && res.state != portClosed {
+				fmt.Fprintf(diag, " (%s)", res.err)
+			}
+			fmt.Fprintln(diag)
+		}
+	}
+	if !found {
+		return errNoOpenPorts
+	}
+	return nil
+}
+
+// scan проверяет порты ports узла с адресами hosts, выполняя не более scanWorkers проверок одновременно.
+// Результаты возвращаются в порядке ports
+func scan(ctx context.Context, network string, hosts []string, ports []int, timeout time.Duration) []scanResult {
+	results := make([]scanResult, len(ports))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	workers := scanWorkers
+	if len(ports) < workers {
+		workers = len(ports)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = probeHosts(ctx, network, hosts, ports[i], timeout)
+			}
+		}()
+	}
+	for i := range ports {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// portStates - состояния порта по возрастанию открытости
+var portStates = map[string]int{portClosed: 0, portFiltered: 1, portOpenFiltered: 2, portOpen: 3}
+
+// probeHosts проверяет порт по каждому адресу узла, как net.Dial перебирает адреса при подключении:
+// порт закрыт, только если он закрыт по всем адресам. Возвращает наиболее открытое состояние
+func probeHosts(ctx context.Context, network string, hosts []string, port int, timeout time.Duration) scanResult {
+	var best scanResult
+	for i, host := range hosts {
+		res := probe(ctx, network, host, port, timeout)
+		if i == 0 || portStates[res.state] > portStates[best.state] {
+			best = res
+		}
+		if best.state == portOpen || ctx.Err() != nil {
+			break
+		}
+	}
+	return best
+}
+
+// probe проверяет один порт по адресу host. TCP: порт открыт, если подключение установлено, закрыт, если
+// подключение отклонено, и фильтруется, если ответа нет. UDP: отправляется пустая датаграмма;
+// ответ означает, что порт открыт, ICMP "порт недоступен" - что закрыт
+func probe(ctx context.Context, network, host string, port int, timeout time.Duration) scanResult {
+	res := scanResult{port: port}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		res.state, res.err = failedState(err), err
+		return res
+	}
+	defer conn.Close()
+	if !strings.HasPrefix(network, "udp") {
+		res.state = portOpen
+		return res
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte{}); err != nil {
+		res.state, res.err = failedState(err), err
+		return res
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			res.state = portOpenFiltered
+			return res
+		}
+		res.state, res.err = failedState(err), err
+		return res
+	}
+	res.state = portOpen
+	return res
+}
+
+// failedState возвращает состояние порта по ошибке подключения
+func failedState(err error) string {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return portClosed
+	}
+	return portFiltered
+}
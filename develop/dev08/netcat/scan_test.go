@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParsePorts(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []int
+		err      bool
+	}{
+		{"80", []int{80}, false},
+		{"20-23,80,443", []int{20, 21, 22, 23, 80, 443}, false},
+		{"7-7", []int{7}, false},
+		{"25-20", nil, true},
+		{"0", nil, true},
+		{"65536", nil, true},
+		{"1,,2", nil, true},
+		{"1-x", nil, true},
+		{"no-such-service-x", nil, true},
+	}
+
+	for _, testCase := range testCases {
+		res, err := parsePorts(testCase.input)
+		if (err != nil) != testCase.err || !reflect.DeepEqual(res, testCase.expected) {
+			t.Errorf("testing %q, expected: %v (error %v), got: %v (%v)", testCase.input, testCase.expected, testCase.err, res, err)
+		}
+	}
+}
+
+// freePort возвращает порт network на 127.0.0.1, который никто не слушает
+func freePort(t *testing.T, network string) int {
+	t.Helper()
+	var addr net.Addr
+	if network == "udp" {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:")
+		if err != nil {
+			t.Fatalf("expected no error on listen, got: %s", err)
+		}
+		addr = pc.LocalAddr()
+		pc.Close()
+	} else {
+		l, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatalf("expected no error on listen, got: %s", err)
+		}
+		addr = l.Addr()
+		l.Close()
+	}
+	_, port, _ := net.SplitHostPort(addr.String())
+	res, _ := strconv.Atoi(port)
+	return res
+}
+
+func TestScan(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	defer l.Close()
+	openTCP := l.Addr().(*net.TCPAddr).Port
+	closedTCP := freePort(t, "tcp")
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	defer pc.Close()
+	silentUDP := pc.LocalAddr().(*net.UDPAddr).Port
+	closedUDP := freePort(t, "udp")
+
+	testCases := []struct {
+		network  string
+		ports    []int
+		expected []string
+	}{
+		{"tcp", []int{openTCP, closedTCP}, []string{portOpen, portClosed}},
+		{"udp", []int{silentUDP, closedUDP}, []string{portOpenFiltered, portClosed}},
+	}
+
+	for _, testCase := range testCases {
+		results := scan(context.Background(), testCase.network, []string{"127.0.0.1"}, testCase.ports, 200*time.Millisecond)
+		var states []string
+		for i, res := range results {
+			if res.port != testCase.ports[i] {
+				t.Errorf("testing %s, expected port %d at %d, got: %d", testCase.network, testCase.ports[i], i, res.port)
+			}
+			states = append(states, res.state)
+		}
+		if !reflect.DeepEqual(states, testCase.expected) {
+			t.Errorf("testing %s %v, expected: %v, got: %v", testCase.network, testCase.ports, testCase.expected, states)
+		}
+	}
+
+	// больше портов, чем одновременных подключений
+	var many []int
+	for i := 0; i < 3*scanWorkers; i++ {
+		many = append(many, closedTCP)
+	}
+	many[len(many)-1] = openTCP
+	results := scan(context.Background(), "tcp", []string{"127.0.0.1"}, many, time.Second)
+	if len(results) != len(many) || results[len(many)-2].state != portClosed || results[len(many)-1].state != portOpen {
+		t.Errorf("unexpected results for %d ports", len(many))
+	}
+
+	// порт открыт только по второму адресу узла (сервер слушает лишь 127.0.0.1)
+	results = scan(context.Background(), "tcp", []string{"127.0.0.2", "127.0.0.1"}, []int{openTCP, closedTCP}, time.Second)
+	if results[0].state != portOpen || results[1].state != portClosed {
+		t.Errorf("expected port open on second address, got: %s %s", results[0].state, results[1].state)
+	}
+}
+
+func TestRunScan(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	defer l.Close()
+	open := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	closed := strconv.Itoa(freePort(t, "tcp"))
+
+	diag := &bytes.Buffer{}
+	opts := options{scan: true, verbose: true, args: []string{"127.0.0.1", closed, open}}
+	if err := run(context.Background(), opts, nil, nil, diag); err != nil {
+		t.Errorf("expected no error, got: %s", err)
+	}
+	expected := "127.0.0.1 " + closed + "/tcp closed\n127.0.0.1 " + open + "/tcp open\n"
+	if diag.String() != expected {
+		t.Errorf("expected report: %q, got: %q", expected, diag.String())
+	}
+
+	diag.Reset()
+	opts = options{scan: true, args: []string{"127.0.0.1", closed}}
+	if err := run(context.Background(), opts, nil, nil, diag); err != errNoOpenPorts || diag.Len() != 0 {
+		t.Errorf("expected silent no open ports error, got: %v %q", err, diag.String())
+	}
+	opts = options{scan: true, args: []string{"127.0.0.1"}}
+	if err := run(context.Background(), opts, nil, nil, diag); err != errUsage {
+		t.Errorf("expected usage error, got: %v", err)
+	}
+}
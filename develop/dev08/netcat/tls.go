@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// tlsOptions - параметры TLS (--ssl)
+type tlsOptions struct {
+	enabled    bool   // использовать TLS
+	cert       string // файл сертификата (PEM): сертификат сервера или клиента
+	key        string // файл закрытого ключа сертификата (PEM)
+	trustFile  string // файл сертификатов доверенных центров сертификации (PEM)
+	verify     bool   // сервер: требовать и проверять сертификат клиента
+	insecure   bool   // клиент: не проверять сертификат сервера
+	serverName string // имя сервера для SNI и проверки сертификата вместо HOST
+}
+
+// tlsVersions - названия версий протокола
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// handshaker - соединение, требующее согласования перед обменом данными (TLS)
+type handshaker interface {
+	HandshakeContext(ctx context.Context) error
+}
+
+// clientTLSConfig возвращает конфигурацию TLS для подключения к host
+func clientTLSConfig(opts tlsOptions, host string) (*tls.Config, error) {
+	if opts.verify && opts.insecure {
+		return nil, errors.New("--ssl-verify and --insecure are mutually exclusive")
+	}
+	config := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: opts.insecure,
+	}
+	if opts.serverName != "" {
+		config.ServerName = opts.serverName
+	}
+	if opts.trustFile != "" {
+		pool, err := loadCertPool(opts.trustFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if opts.cert != "" || opts.key != "" {
+		cert, err := loadKeyPair(opts)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// serverTLSConfig возвращает конфигурацию TLS для сервера на host. Без --ssl-cert
+// создается самоподписанный сертификат, о котором при verbose сообщается в diag
+func serverTLSConfig(opts tlsOptions, host string, verbose bool, diag io.Writer) (*tls.Config, error) {
+	config := &tls.Config{}
+	if opts.cert != "" || opts.key != "" {
+		cert, err := loadKeyPair(opts)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	} else {
+		cert, err := selfSignedCert(host)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+		if verbose {
+			fmt.Fprintf(diag, "generated self-signed certificate, SHA-256 fingerprint %s\n", fingerprint(cert.Certificate[0]))
+		}
+	}
+	if opts.verify {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if opts.trustFile != "" {
+			pool, err := loadCertPool(opts.trustFile)
+			if err != nil {
+				return nil, err
+			}
+			config.ClientCAs = pool
+		}
+	}
+	return config, nil
+}
+
+// loadKeyPair загружает сертификат и ключ; если ключ не указан, он ищется в файле сертификата
+func loadKeyPair(opts tlsOptions) (tls.Certificate, error) {
+	if opts.cert == "" {
+		return tls.Certificate{}, errors.New("--ssl-key requires --ssl-cert")
+	}
+	key := opts.key
+	if key == "" {
+		key = opts.cert
+	}
+	return tls.LoadX509KeyPair(opts.cert, key)
+}
+
+// loadCertPool загружает сертификаты центров сертификации из PEM-файла
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no certificates found", file)
+	}
+	return pool, nil
+}
+
+// selfSignedCert создает самоподписанный сертификат для localhost и host, действительный сутки
+func selfSignedCert(host string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "netcat"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if host != "" {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// handshake выполняет согласование TLS на conn, если это соединение TLS, и при verbose
+// выводит в diag версию протокола, шифр и цепочку сертификатов удаленной стороны
+func handshake(ctx context.Context, conn net.Conn, verbose bool, diag io.Writer) error {
+	h, ok := conn.(handshaker)
+	if !ok {
+		return nil
+	}
+	if err := h.HandshakeContext(ctx); err != nil {
+		return err
+	}
+	if verbose {
+		printTLSState(diag, conn.(*tls.Conn).ConnectionState())
+	}
+	return nil
+}
+
+// printTLSState выводит параметры установленного соединения TLS
+func printTLSState(w io.Writer, state tls.ConnectionState) {
+	version, ok := tlsVersions[state.Version]
+	if !ok {
+		version = fmt.Sprintf("TLS 0x%04x", state.Version)
+	}
+	fmt.Fprintf(w, "%s, cipher %s\n", version, tls.CipherSuiteName(state.CipherSuite))
+	if state.ServerName != "" {
+		fmt.Fprintf(w, "server name: %s\n", state.ServerName)
+	}
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "certificate chain:")
+	for i, cert := range state.PeerCertificates {
+		fmt.Fprintf(w, "%2d subject: %s\n", i, cert.Subject)
+		fmt.Fprintf(w, "   issuer: %s\n", cert.Issuer)
+		fmt.Fprintf(w, "   valid: %s - %s\n", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+		fmt.Fprintf(w, "   SHA-256: %s\n", fingerprint(cert.Raw))
+	}
+}
+
+// fingerprint возвращает отпечаток SHA-256 сертификата в DER
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKeyPair сохраняет сертификат и ключ в PEM-файлы в dir
+func writeKeyPair(t *testing.T, dir, name string, cert tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0644)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600)
+	return certFile, keyFile
+}
+
+// startTLSServer запускает эхо-сервер TLS с конфигурацией config и возвращает его адрес
+func startTLSServer(t *testing.T, config *tls.Config) (host, port string, stop func()) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	l = tls.NewListener(l, config)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	host, port, _ = net.SplitHostPort(l.Addr().String())
+	return host, port, func() { l.Close() }
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netcat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	serverCert, err := selfSignedCert("127.0.0.1")
+	if err != nil {
+		t.Fatalf("expected no error on certificate generation, got: %s", err)
+	}
+	certFile, keyFile := writeKeyPair(t, dir, "server", serverCert)
+	clientCert, _ := selfSignedCert("")
+	clientCertFile, clientKeyFile := writeKeyPair(t, dir, "client", clientCert)
+
+	config, err := serverTLSConfig(tlsOptions{cert: certFile, key: keyFile}, "", false, nil)
+	if err != nil {
+		t.Fatalf("expected no error on server config, got: %s", err)
+	}
+	host, port, stop := startTLSServer(t, config)
+	defer stop()
+
+	// сервер, требующий сертификат клиента
+	config, err = serverTLSConfig(tlsOptions{cert: certFile, key: keyFile, verify: true, trustFile: clientCertFile}, "", false, nil)
+	if err != nil {
+		t.Fatalf("expected no error on server config, got: %s", err)
+	}
+	_, mutualPort, stopMutual := startTLSServer(t, config)
+	defer stopMutual()
+
+	testCases := []struct {
+		name string
+		port string
+		tls  tlsOptions
+		err  bool
+	}{
+		{"trusted", port, tlsOptions{trustFile: certFile}, false},
+		{"unknown authority", port, tlsOptions{}, true},
+		{"insecure", port, tlsOptions{insecure: true}, false},
+		{"server name mismatch", port, tlsOptions{trustFile: certFile, serverName: "example.com"}, true},
+		{"server name", port, tlsOptions{trustFile: certFile, serverName: "localhost"}, false},
+		{"verify and insecure", port, tlsOptions{verify: true, insecure: true}, true},
+		{"no client certificate", mutualPort, tlsOptions{trustFile: certFile}, true},
+		{"client certificate", mutualPort, tlsOptions{trustFile: certFile, cert: clientCertFile, key: clientKeyFile}, false},
+	}
+
+	for _, testCase := range testCases {
+		testCase.tls.enabled = true
+		out := &bytes.Buffer{}
+		opts := options{args: []string{host, testCase.port}, quit: -1, tls: testCase.tls}
+		err := run(context.Background(), opts, strings.NewReader("ping\n"), out, ioutil.Discard)
+		if (err != nil) != testCase.err {
+			t.Errorf("testing %s, expected error %v, got: %v", testCase.name, testCase.err, err)
+		}
+		if err == nil && out.String() != "ping\n" {
+			t.Errorf("testing %s, expected echo, got: %q", testCase.name, out.String())
+		}
+	}
+}
+
+func TestTLSVerbose(t *testing.T) {
+	diag := &bytes.Buffer{}
+	config, err := serverTLSConfig(tlsOptions{}, "127.0.0.1", true, diag)
+	if err != nil {
+		t.Fatalf("expected no error on server config, got: %s", err)
+	}
+	if !strings.HasPrefix(diag.String(), "generated self-signed certificate, SHA-256 fingerprint ") {
+		t.Errorf("expected generated certificate report, got: %q", diag.String())
+	}
+	host, port, stop := startTLSServer(t, config)
+	defer stop()
+
+	diag.Reset()
+	opts := options{args: []string{host, port}, quit: -1, verbose: true, tls: tlsOptions{enabled: true, insecure: true}}
+	if err := run(context.Background(), opts, strings.NewReader(""), ioutil.Discard, diag); err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	for _, expected := range []string{"connected to ", "TLS 1.3, cipher TLS_", "certificate chain:\n 0 subject: CN=netcat\n   issuer: CN=netcat\n"} {
+		if !strings.Contains(diag.String(), expected) {
+			t.Errorf("expected %q in verbose output, got: %q", expected, diag.String())
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// closeIdle - сколько после закрытия соединения удаленной стороной ждать данных из ввода.
+// Закрыла ли удаленная сторона соединение полностью или только на запись, можно узнать,
+// лишь отправив ей данные; если ввод их не дает, передача завершается
+const closeIdle = 200 * time.Millisecond
+
+// transfer передает данные из in в conn и из conn в out, пока удаленная сторона не закроет
+// соединение, не истечет время quit после конца ввода или не будет отменен ctx.
+// После закрытия соединения удаленной стороной данные из in еще отправляются: она могла
+// закрыть его только на запись. Передача завершается в конце in, при отказе удаленной
+// стороны принимать данные или если in не дает данных дольше closeIdle.
+// Закрывает conn. Возвращает первую ошибку передачи данных или nil при штатном завершении
+func transfer(ctx context.Context, conn net.Conn, in io.Reader, out io.Writer, quit time.Duration) error {
+	defer conn.Close()
+	// закрытие соединения при отмене прерывает копирование в горутинах
+	defer closeOnDone(ctx, conn)()
+
+	errorChan := make(chan error, 2)
+	eofChan := make(chan struct{})
+	closedChan := make(chan struct{})
+
+	input := &countingReader{r: in}
+	go send(input, conn, eofChan, errorChan)
+	go recv(conn, out, closedChan, errorChan)
+
+	// получение из nil-канала блокируется, поэтому завершенное направление больше не выбирается
+	var quitChan, idleChan <-chan time.Time
+	var read int64
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-eofChan:
+			eofChan = nil
+			if closedChan == nil {
+				return nil
+			}
+			if quit >= 0 {
+				quitChan = time.After(quit)
+			}
+		case <-quitChan:
+			return nil
+		case <-closedChan:
+			closedChan = nil
+			if eofChan == nil {
+				return nil
+			}
+			read = input.count()
+			idleChan = time.After(closeIdle)
+		case <-idleChan:
+			n := input.count()
+			if n == read {
+				return nil
+			}
+			read = n
+			idleChan = time.After(closeIdle)
+		case err := <-errorChan:
+			// ошибка из-за закрытия соединения при отмене не является ошибкой передачи
+			if ctx.Err() != nil {
+				return nil
+			}
+			// удаленная сторона, закрывшая соединение полностью, отвергает дальнейшие данные
+			if closedChan == nil && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// countingReader считает байты, прочитанные из r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+// count возвращает число прочитанных байт
+func (c *countingReader) count() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// send копирует данные из in в conn. По концу ввода закрывает соединение на запись
+// (удаленная сторона получает EOF, но может продолжать отправлять данные) и закрывает eofChan
+func send(in io.Reader, conn net.Conn, eofChan chan<- struct{}, errorChan chan<- error) {
+	if _, err := io.Copy(conn, in); err != nil {
+		errorChan <- err
+		return
+	}
+	if err := closeWrite(conn); err != nil {
+		errorChan <- err
+		return
+	}
+	close(eofChan)
+}
+
+// recv копирует данные из conn в out. Когда удаленная сторона закрывает соединение, закрывает closedChan
+func recv(conn net.Conn, out io.Writer, closedChan chan<- struct{}, errorChan chan<- error) {
+	if _, err := io.Copy(out, conn); err != nil {
+		errorChan <- err
+		return
+	}
+	close(closedChan)
+}
+
+// closeWrite закрывает соединение на запись, если это поддерживает его тип (TCP, сокеты Unix)
+func closeWrite(conn net.Conn) error {
+	if c, ok := conn.(interface{ CloseWrite() error }); ok {
+		return c.CloseWrite()
+	}
+	return nil
+}
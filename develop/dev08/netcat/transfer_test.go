@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendRecv(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	defer l.Close()
+
+	// двоичные данные без завершающего перевода строки, с символами формата
+	data := []byte("100% \x00\xff\n\n%s%d\r\nend")
+	reply := []byte("reply %v\x00")
+
+	// сервер читает данные до EOF (половинное закрытие клиентом), затем отвечает и закрывает соединение
+	serverErr := make(chan error, 1)
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		res, err := ioutil.ReadAll(conn)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		received <- res
+		_, err = conn.Write(reply)
+		serverErr <- err
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("expected no error on dial, got: %s", err)
+	}
+	defer conn.Close()
+
+	out := &bytes.Buffer{}
+	errorChan := make(chan error, 2)
+	eofChan := make(chan struct{})
+	closedChan := make(chan struct{})
+	go send(bytes.NewReader(data), conn, eofChan, errorChan)
+	go recv(conn, out, closedChan, errorChan)
+
+	for _, ch := range []chan struct{}{eofChan, closedChan} {
+		select {
+		case <-ch:
+		case err := <-errorChan:
+			t.Fatalf("expected no error, got: %s", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+
+	if err := <-serverErr; err != nil {
+		t.Fatalf("expected no server error, got: %s", err)
+	}
+	if res := <-received; !bytes.Equal(res, data) {
+		t.Errorf("expected server to receive: %q, got: %q", data, res)
+	}
+	if !bytes.Equal(out.Bytes(), reply) {
+		t.Errorf("expected client to receive: %q, got: %q", reply, out.Bytes())
+	}
+}
+
+// startServer запускает TCP-сервер, обрабатывающий одно соединение функцией handle, и возвращает его адрес
+func startServer(t *testing.T, handle func(conn *net.TCPConn)) (host, port string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn.(*net.TCPConn))
+	}()
+	host, port, _ = net.SplitHostPort(l.Addr().String())
+	return host, port
+}
+
+func TestRun(t *testing.T) {
+	// порт, на котором гарантированно никто не слушает
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatalf("expected no error on listen, got: %s", err)
+	}
+	_, closedPort, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+
+	testCases := []struct {
+		name   string
+		args   func() []string
+		input  string
+		output string
+		err    bool
+	}{
+		{"echo", func() []string {
+			host, port := startServer(t, func(conn *net.TCPConn) { io.Copy(conn, conn) })
+			return []string{host, port}
+		}, "ping\n", "ping\n", false},
+		{"reply and close", func() []string {
+			host, port := startServer(t, func(conn *net.TCPConn) { conn.Write([]byte("bye")) })
+			return []string{host, port}
+		}, "", "bye", false},
+		{"reset", func() []string {
+			host, port := startServer(t, func(conn *net.TCPConn) {
+				ioutil.ReadAll(conn)
+				conn.SetLinger(0)
+			})
+			return []string{host, port}
+		}, "data", "", true},
+		{"refused", func() []string { return []string{"127.0.0.1", closedPort} }, "", "", true},
+	}
+
+	for _, testCase := range testCases {
+		out := &bytes.Buffer{}
+		opts := options{args: testCase.args(), quit: -1}
+		err := run(context.Background(), opts, strings.NewReader(testCase.input), out, ioutil.Discard)
+		if (err != nil) != testCase.err || out.String() != testCase.output {
+			t.Errorf("testing %s, expected: %q (error %v), got: %q (%v)", testCase.name, testCase.output, testCase.err, out.String(), err)
+		}
+	}
+
+	// удаленная сторона сразу закрывает соединение на запись, но принимает все данные
+	received := make(chan int, 1)
+	host, port := startServer(t, func(conn *net.TCPConn) {
+		conn.CloseWrite()
+		data, _ := ioutil.ReadAll(conn)
+		received <- len(data)
+	})
+	payload := bytes.Repeat([]byte("0123456789"), 20000)
+	opts := options{args: []string{host, port}, quit: -1}
+	if err := run(context.Background(), opts, bytes.NewReader(payload), ioutil.Discard, ioutil.Discard); err != nil {
+		t.Errorf("testing half-closed peer, expected no error, got: %s", err)
+	}
+	select {
+	case n := <-received:
+		if n != len(payload) {
+			t.Errorf("testing half-closed peer, expected %d bytes, got: %d", len(payload), n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("testing half-closed peer: timeout")
+	}
+
+	// удаленная сторона закрывает соединение, пока ввод еще открыт и не дает данных
+	host, port = startServer(t, func(conn *net.TCPConn) { conn.Write([]byte("banner")) })
+	out := &bytes.Buffer{}
+	if err := runOpenInput(t, options{args: []string{host, port}, quit: -1}, out); err != nil || out.String() != "banner" {
+		t.Errorf("testing server closing first, expected: %q, got: %q (%v)", "banner", out.String(), err)
+	}
+
+	// то же в режиме сервера: клиент отключается, пока ввод сервера открыт
+	path := filepath.Join(t.TempDir(), "sock")
+	go func() {
+		var conn net.Conn
+		for i := 0; i < 500 && conn == nil; i++ {
+			conn, _ = net.Dial("unix", path)
+			time.Sleep(10 * time.Millisecond)
+		}
+		if conn != nil {
+			conn.Write([]byte("hello"))
+			conn.Close()
+		}
+	}()
+	out = &bytes.Buffer{}
+	if err := runOpenInput(t, options{unix: true, listen: true, args: []string{path}, quit: -1}, out); err != nil || out.String() != "hello" {
+		t.Errorf("testing client closing first, expected: %q, got: %q (%v)", "hello", out.String(), err)
+	}
+
+	if err := run(context.Background(), options{args: []string{"host"}}, nil, nil, nil); err != errUsage {
+		t.Errorf("expected usage error, got: %v", err)
+	}
+
+	// отмена контекста завершает передачу без ошибки, даже если соединение не закрыто
+	host, port = startServer(t, func(conn *net.TCPConn) { ioutil.ReadAll(conn) })
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	in, stdin := io.Pipe()
+	defer stdin.Close()
+	if err := run(ctx, options{args: []string{host, port}, quit: -1}, in, ioutil.Discard, ioutil.Discard); err != nil {
+		t.Errorf("expected no error after cancel, got: %s", err)
+	}
+}
+
+// runOpenInput выполняет netcat с вводом, который остается открытым и не дает данных.
+// Возвращает ошибку run; если netcat не завершается за 5 секунд, тест проваливается
+func runOpenInput(t *testing.T, opts options, out io.Writer) error {
+	t.Helper()
+	in, stdin := io.Pipe()
+	defer stdin.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := run(ctx, opts, in, out, ioutil.Discard)
+	if ctx.Err() != nil {
+		t.Errorf("netcat did not exit after the remote side closed the connection")
+	}
+	return err
+}
@@ -0,0 +1,581 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// builtinFunc - встроенная команда; args - аргументы без имени команды,
+// j - задание, в котором она выполняется
+type builtinFunc func(args []string, std stdio, j *job) error
+
+// builtins - команды, реализованные собственноручно. Заполняется в init: команды
+// (source, exit) ссылаются на выполнение списков команд, которое само обращается к builtins
+var builtins map[string]builtinFunc
+
+func init() {
+	builtins = map[string]builtinFunc{
+		"pwd":     func(args []string, std stdio, j *job) error { return getwd(std, j.scope()) },
+		"cd":      scoped(cd),
+		"echo":    plain(echo),
+		"read":    scoped(processRead),
+		"ps":      plain(processPS),
+		"kill":    plain(kill),
+		"jobs":    plain(processJobs),
+		"fg":      plain(processFg),
+		"bg":      plain(processBg),
+		"wait":    plain(processWait),
+		"export":  scoped(processExport),
+		"unset":   scoped(processUnset),
+		"set":     scoped(processSet),
+		"trap":    scoped(processTrap),
+		"alias":   scoped(processAlias),
+		"unalias": scoped(processUnalias),
+		"type":    scoped(processType),
+		"which":   scoped(processWhich),
+		"history": plain(processHistory),
+		"dirs":    scoped(processDirs),
+		"pushd":   scoped(processPushd),
+		"popd":    scoped(processPopd),
+		"source":  processSource,
+		".":       processSource,
+		"break": func(args []string, std stdio, j *job) error {
+			return processLoopControl("break", args, std, j)
+		},
+		"continue": func(args []string, std stdio, j *job) error {
+			return processLoopControl("continue", args, std, j)
+		},
+		"return": func(args []string, std stdio, j *job) error { return processReturn(args, j) },
+		"exit":   func(args []string, std stdio, j *job) error { return processExit(args, j) },
+	}
+}
+
+// plain приводит встроенную команду, которой не нужно задание, к builtinFunc
+func plain(f func(args []string, std stdio) error) builtinFunc {
+	return func(args []string, std stdio, j *job) error { return f(args, std) }
+}
+
+// scoped приводит встроенную команду, которой нужно только состояние шелла (подоболочки), к builtinFunc
+func scoped(f func(args []string, std stdio, s *scope) error) builtinFunc {
+	return func(args []string, std stdio, j *job) error { return f(args, std, j.scope()) }
+}
+
+// isBuiltin сообщает, реализована ли команда собственноручно
+func isBuiltin(name string) bool {
+	_, ok := builtins[name]
+	return ok
+}
+
+// keywords - зарезервированные слова шелла (для type)
+var keywords = []string{"if", "then", "elif", "else", "fi", "while", "for", "in", "do", "done",
+	"case", "esac", "function", "{", "}"}
+
+// getwd эмулирует команду pwd, пишет результат в std.out
+func getwd(std stdio, s *scope) error {
+	res, err := s.getwd()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(std.out, res)
+	return err
+}
+
+// cd эмулирует одноименную команду; без аргументов переходит в $HOME,
+// "cd -" - в предыдущую директорию ($OLDPWD), выводя ее
+func cd(args []string, std stdio, s *scope) error {
+	if len(args) > 1 {
+		return errors.New("too many arguments")
+	}
+	dir, ok := s.vars.get("HOME")
+	if !ok && len(args) == 0 {
+		return errors.New("HOME not set")
+	}
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	if dir == "-" {
+		if dir, ok = s.vars.get("OLDPWD"); !ok {
+			return errors.New("OLDPWD not set")
+		}
+		if err := changeDir(dir, s); err != nil {
+			return err
+		}
+		return getwd(std, s)
+	}
+	return changeDir(dir, s)
+}
+
+// changeDir меняет рабочую директорию s и обновляет $PWD и $OLDPWD
+func changeDir(dir string, s *scope) error {
+	old, err := s.getwd()
+	if err != nil {
+		old, _ = s.vars.get("PWD")
+	}
+	if err := s.chdir(dir); err != nil {
+		return err
+	}
+	s.vars.set("OLDPWD", old)
+	if wd, err := s.getwd(); err == nil {
+		s.vars.set("PWD", wd)
+	}
+	return nil
+}
+
+// echo эмулирует одноименную команду, пишет результат в std.out
+func echo(args []string, std stdio) error {
+	_, err := fmt.Fprintln(std.out, strings.Join(args, " "))
+	return err
+}
+
+// aliasTable - псевдонимы команд, заданные командой alias
+type aliasTable struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// aliases - псевдонимы шелла. Раскрываются при разборе строки, поэтому псевдоним,
+// заданный в строке, действует со следующей строки
+var aliases = &aliasTable{values: map[string]string{}}
+
+// clone возвращает копию псевдонимов для подоболочки
+func (t *aliasTable) clone() *aliasTable {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := &aliasTable{values: map[string]string{}}
+	for name, value := range t.values {
+		c.values[name] = value
+	}
+	return c
+}
+
+// get возвращает значение псевдонима
+func (t *aliasTable) get(name string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	value, ok := t.values[name]
+	return value, ok
+}
+
+// set задает псевдоним
+func (t *aliasTable) set(name, value string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.values[name] = value
+}
+
+// unset удаляет псевдоним и сообщает, был ли он задан
+func (t *aliasTable) unset(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.values[name]
+	delete(t.values, name)
+	return ok
+}
+
+// names возвращает имена псевдонимов по алфавиту
+func (t *aliasTable) names() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	names := make([]string, 0, len(t.values))
+	for name := range t.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// quote заключает строку в одинарные кавычки так, чтобы шелл прочитал ее обратно
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// processAlias эмулирует команду alias: "alias имя=значение" задает псевдоним,
+// "alias имя" выводит его, без аргументов (или с -p) выводятся все псевдонимы
+func processAlias(args []string, std stdio, s *scope) error {
+	if len(args) == 0 || len(args) == 1 && args[0] == "-p" {
+		args = s.aliases.names()
+	}
+	var res error
+	for _, arg := range args {
+		if i := strings.IndexByte(arg, '='); i > 0 {
+			s.aliases.set(arg[:i], arg[i+1:])
+			continue
+		}
+		value, ok := s.aliases.get(arg)
+		if !ok {
+			reportError(std.err, "alias", fmt.Errorf("%s: not found", arg))
+			res = statusError(1)
+			continue
+		}
+		fmt.Fprintf(std.out, "alias %s=%s\n", arg, quote(value))
+	}
+	return res
+}
+
+// processUnalias эмулирует команду unalias: удаляет псевдонимы, с -a - все
+func processUnalias(args []string, std stdio, s *scope) error {
+	if len(args) == 0 {
+		return errors.New("usage: unalias [-a] name [name ...]")
+	}
+	if args[0] == "-a" {
+		for _, name := range s.aliases.names() {
+			s.aliases.unset(name)
+		}
+		return nil
+	}
+	var res error
+	for _, name := range args {
+		if !s.aliases.unset(name) {
+			reportError(std.err, "unalias", fmt.Errorf("%s: not found", name))
+			res = statusError(1)
+		}
+	}
+	return res
+}
+
+// processSource эмулирует команду source (.): выполняет команды из файла в текущем шелле.
+// Файл без / в имени ищется в $PATH, затем в текущей директории. Аргументы после имени
+// файла становятся позиционными параметрами на время его выполнения; return завершает файл
+func processSource(args []string, std stdio, j *job) error {
+	if len(args) == 0 {
+		return errors.New("filename argument required")
+	}
+	s := j.scope()
+	file := findSource(args[0], s)
+	data, err := ioutil.ReadFile(s.path(file))
+	if err != nil {
+		return err
+	}
+	l, err := s.parse(string(data))
+	if err != nil {
+		reportError(std.err, args[0], err)
+		return statusError(2)
+	}
+
+	if len(args) > 1 {
+		old := s.vars.setPositional(args[1:])
+		defer s.vars.setPositional(old)
+	}
+	j.functions++
+	defer func() { j.functions-- }()
+	status := execList(l, std, j)
+	if j.flow.kind == flowReturn {
+		status = j.flow.status
+		j.flow = flow{}
+	}
+	return statusResult(status)()
+}
+
+// findSource ищет файл для source
+func findSource(name string, s *scope) string {
+	if strings.ContainsRune(name, '/') {
+		return name
+	}
+	path, _ := s.vars.get("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		file := filepath.Join(dir, name)
+		if info, err := os.Stat(s.path(file)); err == nil && info.Mode().IsRegular() {
+			return file
+		}
+	}
+	return name
+}
+
+// commandKind определяет, чем является имя команды: alias, keyword, function, builtin
+// или file (тогда возвращается и путь к файлу). Пустая строка - команда не найдена
+func commandKind(name string, s *scope) (string, string) {
+	if _, ok := s.aliases.get(name); ok {
+		return "alias", ""
+	}
+	for _, keyword := range keywords {
+		if keyword == name {
+			return "keyword", ""
+		}
+	}
+	if s.functions.get(name) != nil {
+		return "function", ""
+	}
+	if isBuiltin(name) {
+		return "builtin", ""
+	}
+	if path, err := s.lookPath(name); err == nil {
+		return "file", path
+	}
+	return "", ""
+}
+
+// processType эмулирует команду type: сообщает, чем является каждое имя;
+// с -t выводит только вид (alias, keyword, function, builtin, file)
+func processType(args []string, std stdio, s *scope) error {
+	short := len(args) > 0 && args[0] == "-t"
+	if short {
+		args = args[1:]
+	}
+	var res error
+	for _, name := range args {
+		kind, path := commandKind(name, s)
+		switch {
+		case kind == "":
+			if !short {
+				reportError(std.err, "type", fmt.Errorf("%s: not found", name))
+			}
+			res = statusError(1)
+		case short:
+			fmt.Fprintln(std.out, kind)
+		case kind == "alias":
+			value, _ := s.aliases.get(name)
+			fmt.Fprintf(std.out, "%s is aliased to `%s'\n", name, value)
+		case kind == "keyword":
+			fmt.Fprintf(std.out, "%s is a shell keyword\n", name)
+		case kind == "function":
+			fmt.Fprintf(std.out, "%s is a function\n", name)
+		case kind == "builtin":
+			fmt.Fprintf(std.out, "%s is a shell builtin\n", name)
+		default:
+			fmt.Fprintf(std.out, "%s is %s\n", name, path)
+		}
+	}
+	return res
+}
+
+// processWhich эмулирует команду which: выводит путь к программе из $PATH,
+// для псевдонимов, функций и встроенных команд - их описание. С -a выводит все
+// найденные в $PATH программы. Код возврата 1, если какое-то имя не найдено
+func processWhich(args []string, std stdio, s *scope) error {
+	all := len(args) > 0 && args[0] == "-a"
+	if all {
+		args = args[1:]
+	}
+	var res error
+	for _, name := range args {
+		kind, _ := commandKind(name, s)
+		switch kind {
+		case "alias":
+			value, _ := s.aliases.get(name)
+			fmt.Fprintf(std.out, "%s: aliased to %s\n", name, value)
+		case "function":
+			fmt.Fprintf(std.out, "%s: shell function\n", name)
+		case "builtin", "keyword":
+			fmt.Fprintf(std.out, "%s: shell built-in command\n", name)
+		}
+		if kind != "" && kind != "file" && !all {
+			continue
+		}
+		paths := findPaths(name, all, s)
+		for _, path := range paths {
+			fmt.Fprintln(std.out, path)
+		}
+		if len(paths) == 0 && kind == "" {
+			res = statusError(1)
+		}
+	}
+	return res
+}
+
+// findPaths ищет исполняемые файлы name в $PATH: первый найденный или, с all, все
+func findPaths(name string, all bool, s *scope) []string {
+	if strings.ContainsRune(name, '/') {
+		if checkExecutable(s.path(name)) == nil {
+			return []string{name}
+		}
+		return nil
+	}
+	var paths []string
+	path, _ := s.vars.get("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		file := filepath.Join(dir, name)
+		if checkExecutable(s.path(file)) == nil {
+			paths = append(paths, file)
+			if !all {
+				break
+			}
+		}
+	}
+	return paths
+}
+
+// lineEditor - редактор строки интерактивного шелла, хранящий историю; nil - история не ведется
+var lineEditor *editor
+
+// processHistory эмулирует команду history: выводит историю команд с номерами,
+// "history N" - последние N строк, "history -c" очищает историю в памяти
+func processHistory(args []string, std stdio) error {
+	if lineEditor == nil {
+		return nil
+	}
+	if len(args) > 0 && args[0] == "-c" {
+		lineEditor.history = nil
+		return nil
+	}
+	history := lineEditor.history
+	first := 0
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: numeric argument required", args[0])
+		}
+		if n < len(history) {
+			first = len(history) - n
+		}
+	}
+	for i := first; i < len(history); i++ {
+		fmt.Fprintf(std.out, "%5d  %s\n", i+1, history[i])
+	}
+	return nil
+}
+
+// directoryStack - стек директорий pushd и popd; текущая директория в нем не хранится,
+// она всегда считается его вершиной
+type directoryStack struct {
+	mu   sync.Mutex
+	dirs []string // dirs[0] - директория под вершиной
+}
+
+// dirStack - стек директорий шелла
+var dirStack = &directoryStack{}
+
+// clone возвращает копию стека для подоболочки
+func (d *directoryStack) clone() *directoryStack {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &directoryStack{dirs: append([]string(nil), d.dirs...)}
+}
+
+// stackDirs возвращает стек директорий вместе с текущей директорией на вершине
+func stackDirs(s *scope) []string {
+	wd, _ := s.getwd()
+	s.dirs.mu.Lock()
+	defer s.dirs.mu.Unlock()
+	return append([]string{wd}, s.dirs.dirs...)
+}
+
+// shortDir заменяет домашнюю директорию в начале пути на ~
+func shortDir(dir string, s *scope) string {
+	home, _ := s.vars.get("HOME")
+	if home != "" && home != "/" && (dir == home || strings.HasPrefix(dir, home+"/")) {
+		return "~" + dir[len(home):]
+	}
+	return dir
+}
+
+// processDirs эмулирует команду dirs: выводит стек директорий, с -v - по строке с номером,
+// с -l - без замены домашней директории на ~, с -c - очищает стек
+func processDirs(args []string, std stdio, s *scope) error {
+	verbose, long := false, false
+	for _, arg := range args {
+		switch arg {
+		case "-c":
+			s.dirs.mu.Lock()
+			s.dirs.dirs = nil
+			s.dirs.mu.Unlock()
+			return nil
+		case "-v":
+			verbose = true
+		case "-l":
+			long = true
+		default:
+			return fmt.Errorf("%s: invalid option", arg)
+		}
+	}
+	dirs := stackDirs(s)
+	for i, dir := range dirs {
+		if !long {
+			dirs[i] = shortDir(dir, s)
+		}
+		if verbose {
+			fmt.Fprintf(std.out, "%2d  %s\n", i, dirs[i])
+		}
+	}
+	if !verbose {
+		fmt.Fprintln(std.out, strings.Join(dirs, " "))
+	}
+	return nil
+}
+
+// processPushd эмулирует команду pushd: кладет текущую директорию в стек и переходит в dir;
+// без аргументов меняет местами две верхние директории стека. Выводит стек
+func processPushd(args []string, std stdio, s *scope) error {
+	if len(args) > 1 {
+		return errors.New("too many arguments")
+	}
+	wd, err := s.getwd()
+	if err != nil {
+		return err
+	}
+	s.dirs.mu.Lock()
+	var dir string
+	if len(args) == 0 {
+		if len(s.dirs.dirs) == 0 {
+			s.dirs.mu.Unlock()
+			return errors.New("no other directory")
+		}
+		dir = s.dirs.dirs[0]
+	} else {
+		dir = args[0]
+	}
+	s.dirs.mu.Unlock()
+
+	if err := changeDir(dir, s); err != nil {
+		return err
+	}
+	s.dirs.mu.Lock()
+	if len(args) == 0 {
+		s.dirs.dirs[0] = wd
+	} else {
+		s.dirs.dirs = append([]string{wd}, s.dirs.dirs...)
+	}
+	s.dirs.mu.Unlock()
+	return processDirs(nil, std, s)
+}
+
+// processPopd эмулирует команду popd: снимает директорию с вершины стека
+// и переходит в следующую. Выводит стек
+func processPopd(args []string, std stdio, s *scope) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+	s.dirs.mu.Lock()
+	if len(s.dirs.dirs) == 0 {
+		s.dirs.mu.Unlock()
+		return errors.New("directory stack empty")
+	}
+	dir := s.dirs.dirs[0]
+	s.dirs.mu.Unlock()
+
+	if err := changeDir(dir, s); err != nil {
+		return err
+	}
+	s.dirs.mu.Lock()
+	s.dirs.dirs = s.dirs.dirs[1:]
+	s.dirs.mu.Unlock()
+	return processDirs(nil, std, s)
+}
+
+// loadRC выполняет команды из ~/.myshellrc при запуске интерактивного шелла
+func loadRC(std stdio) {
+	home, ok := vars.get("HOME")
+	if !ok {
+		return
+	}
+	file := filepath.Join(home, ".myshellrc")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	l, err := parse(string(data))
+	if err != nil {
+		reportError(std.err, file, err)
+		return
+	}
+	execList(l, std, nil)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	defer aliases.unset("ta_echo")
+	defer aliases.unset("ta_loop")
+	defer aliases.unset("ta_a")
+	defer aliases.unset("ta_b")
+
+	// псевдоним действует со следующей строки, поэтому каждая строка выполняется отдельно
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"alias ta_echo='echo [x]' ta_loop='ta_loop; echo'", 0, ""},
+		{"ta_echo a | cat", 0, "[x] a\n"},
+		{"alias ta_echo", 0, "alias ta_echo='echo [x]'\n"},
+		{"'ta_echo' a", 127, "myshell: ta_echo: command not found\n"},
+		{"echo ta_echo", 0, "ta_echo\n"},
+		{"ta_loop", 0, "myshell: ta_loop: command not found\n\n"},
+		{"alias ta_a=ta_b ta_b=ta_a; ta_a", 127, "myshell: ta_a: command not found\n"},
+		{"alias ta_none", 1, "myshell: alias: ta_none: not found\n"},
+		{"unalias ta_echo; unalias ta_echo", 1, "myshell: unalias: ta_echo: not found\n"},
+		{"ta_echo", 127, "myshell: ta_echo: command not found\n"},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || output != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, output)
+		}
+	}
+}
+
+func TestTypeWhich(t *testing.T) {
+	lsPath, err := shell.lookPath("ls")
+	if err != nil {
+		t.Skip("ls not found")
+	}
+	aliases.set("tw_alias", "echo")
+	defer aliases.unset("tw_alias")
+
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"type cd while tw_alias ls", 0, "cd is a shell builtin\nwhile is a shell keyword\n" +
+			"tw_alias is aliased to `echo'\nls is " + lsPath + "\n"},
+		{"tw_f() { :; }; type -t tw_f cd ls; unset -f tw_f", 0, "function\nbuiltin\nfile\n"},
+		{"type no-such-cmd-x", 1, "myshell: type: no-such-cmd-x: not found\n"},
+		{"which ls", 0, lsPath + "\n"},
+		{"which cd tw_alias", 0, "cd: shell built-in command\ntw_alias: aliased to echo\n"},
+		{"which no-such-cmd-x", 1, ""},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || output != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, output)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myshell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "lib.sh")
+	if err := ioutil.WriteFile(file, []byte("TS_X=$1\nts_f() { echo f $TS_X; }\nreturn 3\necho no\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.sh"), []byte("if true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, _ := vars.get("PATH")
+	defer vars.set("PATH", path)
+
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"source " + file + " arg; echo $?; ts_f", 0, "3\nf arg\n"},
+		{"PATH=" + dir + "; . lib.sh b; echo $TS_X", 0, "b\n"},
+		{"source " + filepath.Join(dir, "bad.sh"), 2,
+			"myshell: " + filepath.Join(dir, "bad.sh") + ": syntax error: unexpected end of input\n"},
+		{"source", 1, "myshell: source: filename argument required\n"},
+		{"source " + filepath.Join(dir, "none"), 1, "myshell: source: " + filepath.Join(dir, "none") + ": No such file or directory\n"},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || output != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, output)
+		}
+	}
+}
+
+func TestDirs(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	defer processDirs([]string{"-c"}, stdio{}, shell)
+	home, _ := vars.get("HOME")
+	defer vars.set("HOME", home)
+	vars.set("HOME", "/tmp")
+	os.Chdir("/")
+
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"cd /tmp; cd /; cd -; echo $OLDPWD", 0, "/tmp\n/\n"},
+		{"cd /; cd; pwd", 0, "/tmp\n"},
+		{"(cd /); pwd; echo $PWD", 0, "/tmp\n/tmp\n"},
+		{"cd / | cat; pwd", 0, "/tmp\n"},
+		{"pushd /; pushd /usr", 0, "/ ~\n/usr / ~\n"},
+		{"dirs -v; dirs -l", 0, " 0  /usr\n 1  /\n 2  ~\n/usr / /tmp\n"},
+		{"pushd; pwd", 0, "/ /usr ~\n/\n"},
+		{"popd; popd; popd", 1, "/usr ~\n~\nmyshell: popd: directory stack empty\n"},
+		{"pushd", 1, "myshell: pushd: no other directory\n"},
+		{"pushd /nonexistent", 1, "myshell: pushd: /nonexistent: No such file or directory\n"},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || output != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, output)
+		}
+	}
+
+	vars.unset("OLDPWD")
+	if status, output := run(t, "cd -"); status != 1 || output != "myshell: cd: OLDPWD not set\n" {
+		t.Errorf("expected error without OLDPWD, got: %d %q", status, output)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	defer func() { lineEditor = nil }()
+	lineEditor = newEditor(nil, ioutil.Discard)
+	for _, line := range []string{"echo a", "ls", "history"} {
+		lineEditor.addHistory(line)
+	}
+
+	if _, output := run(t, "history"); output != "    1  echo a\n    2  ls\n    3  history\n" {
+		t.Errorf("unexpected history: %q", output)
+	}
+	if _, output := run(t, "history 1"); output != "    3  history\n" {
+		t.Errorf("unexpected history tail: %q", output)
+	}
+	if _, output := run(t, "history -c; history"); output != "" {
+		t.Errorf("expected empty history, got: %q", output)
+	}
+}
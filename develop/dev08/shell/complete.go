@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// complete дополняет слово перед курсором. Единственный вариант подставляется целиком,
+// иначе подставляется общее начало вариантов; с list варианты выводятся под строкой
+func (e *editor) complete(list bool) {
+	start, candidates := completions(string(e.buf[:e.pos]))
+	if len(candidates) == 0 {
+		return
+	}
+	prefix := string(e.buf[start:e.pos])
+	if len(candidates) == 1 {
+		e.insert(strings.TrimPrefix(candidates[0], prefix))
+		if !strings.HasSuffix(candidates[0], "/") {
+			e.insert(" ")
+		}
+		return
+	}
+	if common := commonPrefix(candidates); len(common) > len(prefix) {
+		e.insert(strings.TrimPrefix(common, prefix))
+		return
+	}
+	if list {
+		fmt.Fprint(e.out, "\r\n", strings.Join(candidates, "  "), "\r\n", e.prompt)
+	}
+}
+
+// completions возвращает начало дополняемого слова в строке line и отсортированные варианты.
+// Первое слово команды дополняется именами встроенных команд и программ из $PATH,
+// остальные слова и слова, содержащие /, - путями к файлам
+func completions(line string) (int, []string) {
+	runes := []rune(line)
+	start := len(runes)
+	for start > 0 && !strings.ContainsRune(" \t|&;()<>", runes[start-1]) {
+		start--
+	}
+	prefix := string(runes[start:])
+
+	// слово - имя команды, если перед ним только пробелы после начала строки или оператора
+	before := strings.TrimRight(string(runes[:start]), " \t")
+	commandPosition := before == "" || strings.ContainsAny(before[len(before)-1:], "|&;(")
+	if commandPosition && !strings.Contains(prefix, "/") {
+		return start, commandCompletions(prefix)
+	}
+	return start, fileCompletions(prefix)
+}
+
+// commandCompletions возвращает встроенные команды и исполняемые файлы из $PATH,
+// имена которых начинаются с prefix
+func commandCompletions(prefix string) []string {
+	seen := map[string]bool{}
+	for name := range builtins {
+		if strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+	path, _ := vars.get("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if strings.HasPrefix(name, prefix) && checkExecutable(filepath.Join(dir, name)) == nil {
+				seen[name] = true
+			}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// fileCompletions возвращает пути, начинающиеся с prefix; к директориям добавляется /.
+// Префикс ~/ раскрывается в домашнюю директорию, но в вариантах остается как есть.
+// Скрытые файлы предлагаются, только если имя в префиксе начинается с точки
+func fileCompletions(prefix string) []string {
+	dir, name := "", prefix
+	if i := strings.LastIndexByte(prefix, '/'); i >= 0 {
+		dir, name = prefix[:i+1], prefix[i+1:]
+	}
+	path := dir
+	if strings.HasPrefix(dir, "~/") {
+		if home, ok := vars.get("HOME"); ok {
+			path = home + dir[1:]
+		}
+	}
+	if path == "" {
+		path = "."
+	}
+
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), name) || strings.HasPrefix(info.Name(), ".") && !strings.HasPrefix(name, ".") {
+			continue
+		}
+		candidate := dir + info.Name()
+		if isDir(filepath.Join(path, info.Name())) {
+			candidate += "/"
+		}
+		seen[candidate] = true
+	}
+	return sortedKeys(seen)
+}
+
+// sortedKeys возвращает отсортированные ключи множества
+func sortedKeys(set map[string]bool) []string {
+	res := make([]string, 0, len(set))
+	for key := range set {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// commonPrefix возвращает общее начало строк
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			runes := []rune(prefix)
+			prefix = string(runes[:len(runes)-1])
+		}
+	}
+	return prefix
+}
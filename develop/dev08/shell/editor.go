@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errInterrupted - ввод строки прерван Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// lineReader - источник строк команд для главного цикла
+type lineReader interface {
+	// readLine выводит приглашение prompt и возвращает строку без перевода строки
+	readLine(prompt string) (string, error)
+}
+
+// plainReader читает строки из неинтерактивного ввода (файл, канал)
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := r.in.ReadString('\n')
+	if err != nil && line != "" {
+		err = nil // последняя строка без перевода строки
+	}
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+// terminalReader читает строки с терминала через редактор строки, переводя терминал
+// в неканонический режим только на время ввода
+type terminalReader struct {
+	fd     int
+	editor *editor
+}
+
+func (r *terminalReader) readLine(prompt string) (string, error) {
+	old, err := enableRawMode(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer setTermios(r.fd, old)
+	return r.editor.edit(prompt)
+}
+
+// newLineReader возвращает редактор строки для терминала или простое чтение строк
+func newLineReader() lineReader {
+	if !interactive {
+		return &plainReader{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	}
+	e := newEditor(os.Stdin, os.Stdout)
+	if home, ok := vars.get("HOME"); ok {
+		e.loadHistory(filepath.Join(home, ".myshell_history"))
+	}
+	lineEditor = e
+	return &terminalReader{fd: 0, editor: e}
+}
+
+// maxHistory - число строк истории, хранимых в памяти
+const maxHistory = 1000
+
+// editor - редактор строки: перемещение курсора, история, обратный поиск (Ctrl-R) и дополнение (Tab)
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  []string
+	histFile string // файл, в который дописываются новые строки истории; "" - история не сохраняется
+
+	// состояние редактируемой строки
+	prompt    string
+	buf       []rune
+	pos       int
+	histIndex int    // позиция в истории при листании, len(history) - новая строка
+	saved     string // новая строка, сохраненная на время листания истории
+	lastTab   bool   // предыдущая клавиша - Tab: повторный Tab выводит варианты дополнения
+}
+
+// newEditor создает редактор, читающий клавиши из in и выводящий строку в out
+func newEditor(in io.Reader, out io.Writer) *editor {
+	return &editor{in: bufio.NewReader(in), out: out}
+}
+
+// ctrl возвращает код клавиши Ctrl+c
+func ctrl(c rune) rune {
+	return c & 0x1f
+}
+
+// edit выводит приглашение и редактирует строку до нажатия Enter.
+// Ctrl-D на пустой строке возвращает io.EOF, Ctrl-C - errInterrupted
+func (e *editor) edit(prompt string) (string, error) {
+	e.prompt = prompt
+	e.buf = nil
+	e.pos = 0
+	e.histIndex = len(e.history)
+	e.saved = ""
+	fmt.Fprint(e.out, prompt)
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(e.buf), err
+		}
+		tab := false
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			line := string(e.buf)
+			e.addHistory(line)
+			return line, nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case ctrl('D'):
+			if len(e.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.delete(e.pos, e.pos+1)
+		case ctrl('A'):
+			e.pos = 0
+		case ctrl('E'):
+			e.pos = len(e.buf)
+		case ctrl('B'):
+			e.move(-1)
+		case ctrl('F'):
+			e.move(1)
+		case 127, ctrl('H'):
+			e.delete(e.pos-1, e.pos)
+		case ctrl('K'):
+			e.delete(e.pos, len(e.buf))
+		case ctrl('U'):
+			e.delete(0, e.pos)
+		case ctrl('W'):
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.delete(start, e.pos)
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J", e.prompt)
+		case ctrl('P'):
+			e.browse(-1)
+		case ctrl('N'):
+			e.browse(1)
+		case ctrl('R'):
+			if e.search() {
+				fmt.Fprint(e.out, "\r\n")
+				line := string(e.buf)
+				e.addHistory(line)
+				return line, nil
+			}
+		case '\t':
+			e.complete(e.lastTab)
+			tab = true
+		case 27:
+			e.escape()
+		default:
+			if r >= ' ' {
+				e.insert(string(r))
+			}
+		}
+		e.lastTab = tab
+		e.refresh()
+	}
+}
+
+// refresh перерисовывает последнюю строку приглашения и редактируемую строку
+// и ставит курсор в позицию pos
+func (e *editor) refresh() {
+	prompt := e.prompt
+	if i := strings.LastIndexByte(prompt, '\n'); i >= 0 {
+		prompt = prompt[i+1:]
+	}
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(e.buf))
+	if n := len(e.buf) - e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+// move сдвигает курсор на delta символов в пределах строки
+func (e *editor) move(delta int) {
+	e.pos += delta
+	if e.pos < 0 {
+		e.pos = 0
+	}
+	if e.pos > len(e.buf) {
+		e.pos = len(e.buf)
+	}
+}
+
+// insert вставляет текст в позицию курсора
+func (e *editor) insert(text string) {
+	runes := []rune(text)
+	buf := append([]rune{}, e.buf[:e.pos]...)
+	buf = append(buf, runes...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(runes)
+}
+
+// delete удаляет символы строки в промежутке [from, to)
+func (e *editor) delete(from, to int) {
+	if from < 0 || to > len(e.buf) || from >= to {
+		return
+	}
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	if e.pos > to {
+		e.pos -= to - from
+	} else if e.pos > from {
+		e.pos = from
+	}
+}
+
+// setLine заменяет редактируемую строку, ставя курсор в конец
+func (e *editor) setLine(line string) {
+	e.buf = []rune(line)
+	e.pos = len(e.buf)
+}
+
+// escape обрабатывает escape-последовательность клавиш управления курсором:
+// стрелки, Home, End и Delete
+func (e *editor) escape() {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != '[' && r != 'O' {
+		return
+	}
+	seq := ""
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return
+		}
+		seq += string(r)
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+	}
+
+	switch seq {
+	case "A":
+		e.browse(-1)
+	case "B":
+		e.browse(1)
+	case "C":
+		e.move(1)
+	case "D":
+		e.move(-1)
+	case "H", "1~", "7~":
+		e.pos = 0
+	case "F", "4~", "8~":
+		e.pos = len(e.buf)
+	case "3~":
+		e.delete(e.pos, e.pos+1)
+	}
+}
+
+// browse листает историю: delta -1 - к более старым строкам, 1 - к более новым
+func (e *editor) browse(delta int) {
+	index := e.histIndex + delta
+	if index < 0 || index > len(e.history) {
+		return
+	}
+	if e.histIndex == len(e.history) {
+		e.saved = string(e.buf)
+	}
+	e.histIndex = index
+	if index == len(e.history) {
+		e.setLine(e.saved)
+		return
+	}
+	e.setLine(e.history[index])
+}
+
+// search выполняет обратный поиск по истории (Ctrl-R). Каждый символ уточняет запрос,
+// повторный Ctrl-R ищет более старое совпадение. Enter выполняет найденную строку
+// (возвращается true), Ctrl-G и Ctrl-C отменяют поиск, любая другая клавиша
+// подставляет найденную строку для редактирования
+func (e *editor) search() bool {
+	original := string(e.buf)
+	query := []rune{}
+	match := len(e.history) - 1
+	found := ""
+	failed := false
+
+	// find ищет запрос, начиная с позиции истории from и двигаясь к более старым строкам
+	find := func(from int) {
+		for i := from; i >= 0 && i < len(e.history); i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				match, found, failed = i, e.history[i], false
+				return
+			}
+		}
+		failed = true
+	}
+
+	for {
+		label := "reverse-i-search"
+		if failed {
+			label = "failed reverse-i-search"
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", label, string(query), found)
+
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return false
+		}
+		switch r {
+		case ctrl('R'):
+			find(match - 1)
+		case 127, ctrl('H'):
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history) - 1)
+			}
+		case ctrl('G'), ctrl('C'):
+			e.setLine(original)
+			return false
+		case '\r', '\n':
+			e.setLine(found)
+			return true
+		default:
+			if r >= ' ' {
+				query = append(query, r)
+				find(match)
+				continue
+			}
+			// клавиша завершает поиск и обрабатывается как обычно
+			e.in.UnreadRune()
+			e.setLine(found)
+			return false
+		}
+	}
+}
+
+// loadHistory загружает историю из файла и запоминает его для сохранения новых строк
+func (e *editor) loadHistory(file string) {
+	e.histFile = file
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// addHistory добавляет строку в историю и дописывает ее в файл истории.
+// Пустые строки и повторы предыдущей строки не сохраняются
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+	if e.histFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.histFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
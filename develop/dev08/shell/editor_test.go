@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditor(t *testing.T) {
+	history := []string{"ls -l", "echo hello", "cat file"}
+	testCases := []struct {
+		keys     string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"abc\x7f\x7fd\r", "ad"},
+		{"ac\x1b[Db\r", "abc"},
+		{"bc\x01a\x05d\r", "abcd"},
+		{"abcd\x02\x02\x0b\r", "ab"},
+		{"abcd\x02\x02\x15\r", "cd"},
+		{"echo one two\x17\r", "echo one "},
+		{"abc\x1b[H\x1b[3~\r", "bc"},
+		{"ab\x02\x04\r", "a"},
+		{"\x1b[A\r", "cat file"},
+		{"\x1b[A\x1b[A\x1b[A\x1b[A\r", "ls -l"},
+		{"new\x10\x0e\r", "new"},
+		{"\x12ech\r", "echo hello"},
+		{"\x12l\x12\r", "echo hello"},
+		{"\x12l\x12\x12\r", "ls -l"},
+		{"\x12cat\x06!\r", "cat file!"},
+		{"x\x12zzz\x07\r", "x"},
+	}
+
+	for _, testCase := range testCases {
+		e := newEditor(strings.NewReader(testCase.keys), ioutil.Discard)
+		e.history = append([]string{}, history...)
+		if res, err := e.edit("$ "); err != nil || res != testCase.expected {
+			t.Errorf("testing %q, expected: %q, got: %q (%v)", testCase.keys, testCase.expected, res, err)
+		}
+	}
+}
+
+func TestEditorControl(t *testing.T) {
+	e := newEditor(strings.NewReader("\x04"), ioutil.Discard)
+	if _, err := e.edit("$ "); err != io.EOF {
+		t.Errorf("Ctrl-D on empty line: expected EOF, got: %v", err)
+	}
+	e = newEditor(strings.NewReader("abc\x03"), ioutil.Discard)
+	if _, err := e.edit("$ "); err != errInterrupted {
+		t.Errorf("Ctrl-C: expected interruption, got: %v", err)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myshell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, ".myshell_history")
+	ioutil.WriteFile(file, []byte("first\nsecond\n"), 0600)
+
+	e := newEditor(strings.NewReader("third\rthird\r \r\x1b[A\x1b[A\r"), ioutil.Discard)
+	e.loadHistory(file)
+	for _, expected := range []string{"third", "third", " ", "second"} {
+		if res, _ := e.edit("$ "); res != expected {
+			t.Errorf("expected: %q, got: %q", expected, res)
+		}
+	}
+	data, _ := ioutil.ReadFile(file)
+	if string(data) != "first\nsecond\nthird\nsecond\n" {
+		t.Errorf("unexpected history file: %q", data)
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myshell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	os.Mkdir("src", 0755)
+	for _, name := range []string{"main.go", "make.sh", ".hidden", "src/a.go"} {
+		ioutil.WriteFile(name, nil, 0644)
+	}
+	ioutil.WriteFile("run.sh", nil, 0755)
+	path, _ := vars.get("PATH")
+	defer vars.set("PATH", path)
+	vars.set("PATH", dir)
+
+	testCases := []struct {
+		line     string
+		start    int
+		expected []string
+	}{
+		{"exp", 0, []string{"export"}},
+		{"ls; ru", 4, []string{"run.sh"}},
+		{"cat ma", 4, []string{"main.go", "make.sh"}},
+		{"cat s", 4, []string{"src/"}},
+		{"cat src/", 4, []string{"src/a.go"}},
+		{"cat .h", 4, []string{".hidden"}},
+		{"cat ", 4, []string{"main.go", "make.sh", "run.sh", "src/"}},
+		{"./r", 0, []string{"./run.sh"}},
+	}
+	for _, testCase := range testCases {
+		start, res := completions(testCase.line)
+		if start != testCase.start || !reflect.DeepEqual(res, testCase.expected) {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.line, testCase.start, testCase.expected, start, res)
+		}
+	}
+
+	e := newEditor(strings.NewReader("cat m\t\tk\t\r"), ioutil.Discard)
+	if res, _ := e.edit("$ "); res != "cat make.sh " {
+		t.Errorf("expected completed line %q, got: %q", "cat make.sh ", res)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Ошибки команд не завершают шелл: сообщение выводится в stderr в виде
+// "myshell: команда: сообщение", а код возврата попадает в $?.
+// Встроенные команды возвращают error: statusError задает код возврата без сообщения,
+// любая другая ошибка выводится и дает код возврата 1.
+
+// statusError - ненулевой код возврата команды
+type statusError int
+
+func (e statusError) Error() string {
+	return "exit status " + strconv.Itoa(int(e))
+}
+
+// errStopped - команда остановлена (Ctrl-Z), а не завершена
+var errStopped = errors.New("stopped")
+
+// exitCode переводит результат ожидания команды в код возврата
+func exitCode(err error) int {
+	var status statusError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errStopped):
+		return 128 + int(syscall.SIGTSTP)
+	case errors.As(err, &status):
+		return int(status)
+	default:
+		return 1
+	}
+}
+
+// statusResult возвращает функцию ожидания для уже выполненной команды с кодом возврата status
+func statusResult(status int) func() error {
+	return func() error {
+		if status != 0 {
+			return statusError(status)
+		}
+		return nil
+	}
+}
+
+// errorMessage возвращает текст ошибки в стиле bash: "file: No such file or directory"
+func errorMessage(err error) string {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Path + ": " + errorMessage(pathErr.Err)
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		msg := errno.Error()
+		return strings.ToUpper(msg[:1]) + msg[1:]
+	}
+	return err.Error()
+}
+
+// reportError выводит сообщение об ошибке команды name; пустое name - ошибка самого шелла
+func reportError(w io.Writer, name string, err error) {
+	if name == "" {
+		fmt.Fprintf(w, "myshell: %s\n", errorMessage(err))
+		return
+	}
+	fmt.Fprintf(w, "myshell: %s: %s\n", name, errorMessage(err))
+}
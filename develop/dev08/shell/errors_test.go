@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCommandErrors(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	defer os.Chdir(wd)
+	home, _ := vars.get("HOME")
+	defer vars.set("HOME", home)
+
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"cd /nonexistent", 1, "myshell: cd: /nonexistent: No such file or directory\n"},
+		{"cd /nonexistent; echo $?", 0, "myshell: cd: /nonexistent: No such file or directory\n1\n"},
+		{"HOME=/; cd && pwd", 0, "/\n"},
+		{"unset HOME; cd", 1, "myshell: cd: HOME not set\n"},
+		{"cd / /tmp", 1, "myshell: cd: too many arguments\n"},
+		{"kill abc", 1, "myshell: kill: abc: arguments must be process or job IDs\n"},
+		{"kill", 1, "myshell: kill: usage: kill [-s sigspec | -n signum | -sigspec] pid | jobspec ... or kill -l [sigspec]\n"},
+		{"no-such-command-x", 127, "myshell: no-such-command-x: command not found\n"},
+		{"/nonexistent/cmd", 127, "myshell: /nonexistent/cmd: No such file or directory\n"},
+		{"/ ; echo $?", 0, "myshell: /: Is a directory\n126\n"},
+		{"cat </nonexistent", 1, "myshell: /nonexistent: No such file or directory\n"},
+		{"fg %9", 1, "myshell: fg: %9: no such job\n"},
+		{"set -o nope", 1, "myshell: set: nope: invalid option name\n"},
+		{"false | true", 0, ""},
+		{"true | false", 1, ""},
+		{"set -o pipefail; sh -c 'exit 3' | false | true; echo $?; set +o pipefail", 0, "1\n"},
+		{"set -o pipefail; sh -c 'exit 3' | true; echo $?; set +o pipefail", 0, "3\n"},
+		{"set -o pipefail; set -o; set +o pipefail", 0, "pipefail       \ton\n"},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || output != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, output)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"os/user"
+	"strings"
+)
+
+// Раскрытие слов выполняется между разбором и выполнением команды в порядке bash:
+// раскрытие фигурных скобок, тильды, переменных и подстановок команд, разбиение
+// результатов подстановок без кавычек на поля по $IFS и раскрытие шаблонов имен файлов.
+// Текст в кавычках не разбивается и не считается шаблоном.
+
+// field - поле (будущий аргумент команды) в процессе раскрытия
+type field struct {
+	text     strings.Builder
+	pattern  strings.Builder // текст для сопоставления с шаблоном: символы из кавычек экранированы
+	glob     bool            // в поле есть *, ? или [ вне кавычек
+	quoted   bool            // в поле есть кавычки - пустое поле остается аргументом
+	lastOpen bool            // последний символ шаблона - [ вне кавычек
+}
+
+// expander раскрывает слова одной команды
+type expander struct {
+	std    stdio // потоки для подстановок команд
+	j      *job  // задание, в котором выполняются подстановки команд
+	fields []*field
+	split  bool // следующий символ начинает новое поле
+}
+
+// newExpander создает раскрытие для команды с потоками std, выполняющейся в задании j
+func newExpander(std stdio, j *job) *expander {
+	return &expander{std: std, j: j}
+}
+
+// words раскрывает слова командной строки в аргументы команды
+func (e *expander) words(words []word) []string {
+	args := []string{}
+	for _, w := range words {
+		args = append(args, e.word(w)...)
+	}
+	return args
+}
+
+// word раскрывает одно слово в ноль или более аргументов
+func (e *expander) word(w word) []string {
+	args := []string{}
+	for _, w := range expandBraces(w) {
+		e.fields = nil
+		e.split = false
+		for _, part := range e.tilde(w) {
+			e.part(part)
+		}
+		for _, f := range e.fields {
+			if f.text.Len() == 0 && !f.quoted {
+				continue
+			}
+			if f.glob {
+				if matches := glob(f.pattern.String(), e.j.scope()); len(matches) > 0 {
+					args = append(args, matches...)
+					continue
+				}
+			}
+			args = append(args, f.text.String())
+		}
+	}
+	return args
+}
+
+// single раскрывает слово в одну строку без разбиения на поля и шаблонов
+// (значения присваиваний и имена файлов перенаправлений)
+func (e *expander) single(w word) string {
+	builder := strings.Builder{}
+	for _, part := range e.tilde(w) {
+		if part.quote == singleQuoted {
+			builder.WriteString(part.text)
+			continue
+		}
+		builder.WriteString(e.text(part.text))
+	}
+	return builder.String()
+}
+
+// text раскрывает переменные и подстановки команд в тексте без разбиения на поля
+func (e *expander) text(text string) string {
+	builder := strings.Builder{}
+	e.scan(text, func(s string, _ bool) {
+		builder.WriteString(s)
+	}, nil)
+	return builder.String()
+}
+
+// pattern раскрывает слово в шаблон без разбиения на поля (шаблоны case):
+// символы шаблона из кавычек экранируются
+func (e *expander) pattern(w word) string {
+	e.fields = nil
+	e.split = false
+	for _, part := range e.tilde(w) {
+		switch part.quote {
+		case singleQuoted:
+			e.literal(part.text, true)
+		case doubleQuoted:
+			e.literal(e.text(part.text), true)
+		default:
+			e.scan(part.text, func(s string, _ bool) {
+				e.literal(s, false)
+			}, nil)
+		}
+	}
+	return e.current().pattern.String()
+}
+
+// part добавляет к полям часть слова
+func (e *expander) part(part wordPart) {
+	switch part.quote {
+	case singleQuoted:
+		e.literal(part.text, true)
+	case doubleQuoted:
+		if part.text == "" { // "" - пустой аргумент
+			e.literal("", true)
+			return
+		}
+		// "$@" дает по отдельному полю на каждый позиционный параметр
+		e.scan(part.text, func(s string, _ bool) {
+			e.literal(s, true)
+		}, func(params []string) {
+			for i, param := range params {
+				e.split = e.split || i > 0
+				e.literal(param, true)
+			}
+		})
+	default:
+		e.scan(part.text, func(s string, expanded bool) {
+			if expanded {
+				e.splitText(s)
+			} else {
+				e.literal(s, false)
+			}
+		}, nil)
+	}
+}
+
+// current возвращает текущее поле, создавая его при необходимости
+func (e *expander) current() *field {
+	if len(e.fields) == 0 || e.split {
+		e.fields = append(e.fields, &field{})
+		e.split = false
+	}
+	return e.fields[len(e.fields)-1]
+}
+
+// literal добавляет текст к текущему полю. Символы шаблона вне кавычек
+// делают поле шаблоном, в кавычках - экранируются
+func (e *expander) literal(s string, quoted bool) {
+	f := e.current()
+	f.quoted = f.quoted || quoted
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		f.text.WriteByte(c)
+		switch {
+		case c == '\\' || quoted && strings.IndexByte("*?[]", c) >= 0:
+			f.pattern.WriteByte('\\')
+			f.pattern.WriteByte(c)
+		case c == '!' && f.lastOpen: // [!...] в bash - то же, что [^...]
+			f.pattern.WriteByte('^')
+		default:
+			f.glob = f.glob || c == '*' || c == '?' || c == '['
+			f.pattern.WriteByte(c)
+		}
+		f.lastOpen = !quoted && c == '['
+	}
+}
+
+// splitText добавляет результат подстановки без кавычек, разбивая его на поля по символам $IFS
+func (e *expander) splitText(s string) {
+	ifs, ok := e.j.scope().vars.get("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	start := 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && strings.IndexByte(ifs, s[i]) < 0 {
+			continue
+		}
+		if i > start {
+			e.literal(s[start:i], false)
+		}
+		if i < len(s) && len(e.fields) > 0 {
+			e.split = true
+		}
+		start = i + 1
+	}
+}
+
+// scan находит в тексте подстановки переменных и команд. Обычный текст передается
+// в emit с expanded = false, результаты подстановок - с expanded = true.
+// Если задан params, $@ и ${@} передаются в него списком позиционных параметров
+func (e *expander) scan(text string, emit func(s string, expanded bool), params func([]string)) {
+	start := 0
+	for i := 0; i < len(text); i++ {
+		var value string
+		var end int
+		switch {
+		case params != nil && (strings.HasPrefix(text[i:], "$@") || strings.HasPrefix(text[i:], "${@}")):
+			if i > start {
+				emit(text[start:i], false)
+			}
+			params(e.j.scope().vars.positional())
+			start = i + 2
+			if text[i+1] == '{' {
+				start = i + 4
+			}
+			i = start - 1
+			continue
+		case text[i] == '`' || strings.HasPrefix(text[i:], "$("):
+			var ok bool
+			if end, ok = substitutionEnd(text, i); !ok {
+				continue
+			}
+			if text[i] == '`' {
+				value = e.substitute(unescapeBackquoted(text[i+1 : end-1]))
+			} else {
+				value = e.substitute(text[i+2 : end-1])
+			}
+		case text[i] == '$':
+			if value, end = e.variable(text, i); end == i {
+				continue
+			}
+		default:
+			continue
+		}
+		if i > start {
+			emit(text[start:i], false)
+		}
+		emit(value, true)
+		start = end
+		i = end - 1
+	}
+	if start < len(text) {
+		emit(text[start:], false)
+	}
+}
+
+// isSpecialVar сообщает, является ли символ именем специальной переменной ($?, $$, $!, $#, $@, $*, $0-$9)
+func isSpecialVar(c byte) bool {
+	return strings.IndexByte("?$!#@*", c) >= 0 || c >= '0' && c <= '9'
+}
+
+// variable раскрывает $NAME, ${...} или специальную переменную в позиции i.
+// Возвращает значение и позицию после подстановки; end == i, если подстановки нет
+func (e *expander) variable(text string, i int) (value string, end int) {
+	if i+1 >= len(text) {
+		return "", i
+	}
+	next := text[i+1]
+	switch {
+	case next == '{':
+		close := strings.IndexByte(text[i:], '}')
+		if close < 0 {
+			return "", i
+		}
+		return e.braced(text[i+2 : i+close]), i + close + 1
+	case isSpecialVar(next):
+		value, _ = e.j.scope().vars.get(text[i+1 : i+2])
+		return value, i + 2
+	case next == '_' || next >= 'a' && next <= 'z' || next >= 'A' && next <= 'Z':
+		end = i + 2
+		for end < len(text) && isName(text[i+1:end+1]) {
+			end++
+		}
+		value, _ = e.j.scope().vars.get(text[i+1 : end])
+		return value, end
+	}
+	return "", i
+}
+
+// braced раскрывает содержимое ${...}: NAME, NAME:-default (значение по умолчанию,
+// если переменная не задана или пуста) и NAME-default (если переменная не задана)
+func (e *expander) braced(inner string) string {
+	name := inner
+	op := ""
+	def := ""
+	if i := strings.IndexByte(inner, '-'); i > 0 {
+		name, op, def = inner[:i], "-", inner[i+1:]
+		if strings.HasSuffix(name, ":") {
+			name, op = name[:len(name)-1], ":-"
+		}
+	}
+
+	value, ok := e.j.scope().vars.get(name)
+	if op == "-" && !ok || op == ":-" && value == "" {
+		return e.text(def)
+	}
+	return value
+}
+
+// unescapeBackquoted убирает экранирование \`, \$ и \\ внутри `...`
+func unescapeBackquoted(s string) string {
+	builder := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("`$\\", s[i+1]) >= 0 {
+			i++
+		}
+		builder.WriteByte(s[i])
+	}
+	return builder.String()
+}
+
+// substitute выполняет подстановку команды: выполняет src собственным исполнителем шелла
+// и возвращает его вывод без завершающих переводов строк. Подстановка выполняется
+// в подоболочке, поэтому присваивания, cd, break, return и exit внутри нее не действуют на шелл
+func (e *expander) substitute(src string) string {
+	l, err := e.j.scope().parse(src)
+	if err != nil {
+		e.std.err.Write([]byte("myshell: " + err.Error() + "\n"))
+		return ""
+	}
+	j := e.j
+	if j == nil { // раскрытие вне команды: программы подстановки образуют отдельное задание
+		j = newJob(src, true)
+		defer jobs.foregroundDone(j, 0, false, e.std.err)
+	}
+	out := bytes.Buffer{}
+	execList(l, stdio{in: e.std.in, out: &out, err: e.std.err}, j.subshell())
+	return strings.TrimRight(out.String(), "\n")
+}
+
+// tilde раскрывает ~ и ~user в начале слова в домашнюю директорию.
+// Результат помечается как текст в кавычках, чтобы не раскрываться дальше
+func (e *expander) tilde(w word) word {
+	if len(w) == 0 || w[0].quote != unquoted || !strings.HasPrefix(w[0].text, "~") {
+		return w
+	}
+	prefix := w[0].text
+	rest := ""
+	if i := strings.IndexByte(prefix, '/'); i >= 0 {
+		prefix, rest = prefix[:i], prefix[i:]
+	} else if len(w) > 1 {
+		return w // ~"user" не раскрывается
+	}
+
+	var home string
+	if prefix == "~" {
+		home, _ = e.j.scope().vars.get("HOME")
+	} else if u, err := user.Lookup(prefix[1:]); err == nil {
+		home = u.HomeDir
+	}
+	if home == "" {
+		return w
+	}
+
+	res := word{{text: home, quote: singleQuoted}}
+	if rest != "" {
+		res = append(res, wordPart{text: rest, quote: unquoted})
+	}
+	return append(res, w[1:]...)
+}
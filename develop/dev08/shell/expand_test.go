@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// expandLine разбирает строку и раскрывает слова первой команды
+func expandLine(t *testing.T, input string) []string {
+	l, err := parse(input)
+	if err != nil {
+		t.Fatalf("testing %q, expected no parse error, got: %s", input, err)
+	}
+	c := l.items[0].andOr.pipelines[0].commands[0].(*simpleCommand)
+	return newExpander(stdio{in: strings.NewReader(""), out: ioutil.Discard, err: ioutil.Discard}, nil).words(c.words)
+}
+
+func TestExpandWords(t *testing.T) {
+	home, _ := vars.get("HOME")
+	defer vars.set("HOME", home)
+	vars.set("TV_NAME", "a b")
+	vars.set("HOME", "/home/tv")
+	vars.unset("TV_UNSET")
+
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{`echo $TV_NAME "$TV_NAME" '$TV_NAME' \$TV_NAME "\$x"`, []string{"echo", "a", "b", "a b", "$TV_NAME", "$TV_NAME", "$x"}},
+		{`echo $TV_UNSET "$TV_UNSET" "${TV_UNSET:-x y}"`, []string{"echo", "", "x y"}},
+		{`echo x$TV_NAME"y"`, []string{"echo", "xa", "by"}},
+		{`echo ~ ~/dir "~" x~ ~"/q"`, []string{"echo", "/home/tv", "/home/tv/dir", "~", "x~", "~/q"}},
+		{`echo a{b,c}d {1..3} {c..a} {x} '{a,b}' ${TV_UNSET}{1,2}`, []string{"echo", "abd", "acd", "1", "2", "3", "c", "b", "a", "{x}", "{a,b}", "1", "2"}},
+		{`echo {a,b}{1,2}`, []string{"echo", "a1", "a2", "b1", "b2"}},
+		{`echo $(echo one two) "$(printf 'one  two')" x$(printf '')y`, []string{"echo", "one", "two", "one  two", "xy"}},
+		{"echo `echo \\`echo nested\\``", []string{"echo", "nested"}},
+		{`echo "$(echo "a)b")"`, []string{"echo", "a)b"}},
+		{`echo $(printf 'line\n\n\n')`, []string{"echo", "line"}},
+	}
+
+	for _, testCase := range testCases {
+		if res := expandLine(t, testCase.input); !reflect.DeepEqual(res, testCase.expected) {
+			t.Errorf("testing %q, expected: %q, got: %q", testCase.input, testCase.expected, res)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myshell")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b.go", "a.go", "c.txt", ".hidden.go", "sub/d.go", "sub/deep/e.go", "sub/.h/f.go", "x[1].go"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, nil, 0644)
+	}
+
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"DIR/*.go", []string{"DIR/a.go", "DIR/b.go", "DIR/x[1].go"}},
+		{"DIR/?.*", []string{"DIR/a.go", "DIR/b.go", "DIR/c.txt"}},
+		{"DIR/[ab].go", []string{"DIR/a.go", "DIR/b.go"}},
+		{"DIR/[!ab].*", []string{"DIR/c.txt"}},
+		{"DIR/.*.go", []string{"DIR/.hidden.go"}},
+		{"DIR/*/*.go", []string{"DIR/sub/d.go"}},
+		{"DIR/**/*.go", []string{"DIR/a.go", "DIR/b.go", "DIR/sub/d.go", "DIR/sub/deep/e.go", "DIR/x[1].go"}},
+		{"DIR/*/", []string{"DIR/sub/"}},
+		{"DIR/*.none", []string{"DIR/*.none"}},
+		{"'DIR/*.go'", []string{"DIR/*.go"}},
+		{`DIR/x\[1\].go`, []string{"DIR/x[1].go"}},
+		{`DIR/"x["*`, []string{"DIR/x[1].go"}},
+	}
+
+	for _, testCase := range testCases {
+		input := strings.Replace(testCase.input, "DIR", dir, -1)
+		expected := []string{"echo"}
+		for _, path := range testCase.expected {
+			expected = append(expected, strings.Replace(path, "DIR", dir, -1))
+		}
+		if res := expandLine(t, "echo "+input); !reflect.DeepEqual(res, expected) {
+			t.Errorf("testing %q, expected: %q, got: %q", testCase.input, expected, res)
+		}
+	}
+}
+
+func TestCommandSubstitution(t *testing.T) {
+	testCases := []struct {
+		input  string
+		output string
+	}{
+		{"TV_S=$(echo a b); echo \"$TV_S\"", "a b\n"},
+		{"echo $(cd /; pwd) && pwd | grep -c '^/$'", "/\n0\n"},
+		{"echo $(echo x | tr x y) `echo z`", "y z\n"},
+		{"cat <<EOF\n$(echo sub)\nEOF\n", "sub\n"},
+	}
+
+	for _, testCase := range testCases {
+		if _, output := run(t, testCase.input); output != testCase.output {
+			t.Errorf("testing %q, expected: %q, got: %q", testCase.input, testCase.output, output)
+		}
+	}
+}
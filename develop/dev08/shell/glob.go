@@ -0,0 +1,311 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hasMeta сообщает, есть ли в сегменте шаблона неэкранированные символы *, ? или [
+func hasMeta(segment string) bool {
+	for i := 0; i < len(segment); i++ {
+		switch segment[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// unescapePattern убирает экранирование из сегмента шаблона без метасимволов
+func unescapePattern(segment string) string {
+	return strings.NewReplacer(`\\`, `\`, `\*`, `*`, `\?`, `?`, `\[`, `[`, `\]`, `]`).Replace(segment)
+}
+
+// matchPattern сопоставляет строку целиком с шаблоном (шаблоны case). В отличие от имен файлов,
+// * и ? соответствуют и символу /. Экранированные символы сравниваются буквально
+func matchPattern(pattern, s string) bool {
+	re := strings.Builder{}
+	re.WriteString("^(?s:")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end == 0 { // ] сразу после [ входит в набор
+				end = strings.IndexByte(pattern[i+2:], ']') + 1
+			}
+			if end <= 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			re.WriteByte('[')
+			if strings.HasPrefix(class, "^") {
+				re.WriteByte('^')
+				class = class[1:]
+			}
+			for j := 0; j < len(class); j++ {
+				if class[j] == '-' && j > 0 && j < len(class)-1 {
+					re.WriteByte('-')
+					continue
+				}
+				re.WriteString(regexp.QuoteMeta(class[j : j+1]))
+			}
+			re.WriteByte(']')
+			i += end + 1
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString(")$")
+	matched, err := regexp.MatchString(re.String(), s)
+	return err == nil && matched
+}
+
+// glob возвращает отсортированный список путей, соответствующих шаблону.
+// Сегмент ** соответствует любому числу вложенных директорий. Скрытые файлы
+// соответствуют только сегментам, которые сами начинаются с точки.
+// Относительные пути отсчитываются от рабочей директории s, но возвращаются без нее
+func glob(pattern string, s *scope) []string {
+	g := globber{s}
+	dir := ""
+	if strings.HasPrefix(pattern, "/") {
+		dir = "/"
+		pattern = strings.TrimLeft(pattern, "/")
+	}
+	matches := g.segments(dir, strings.Split(pattern, "/"))
+	sort.Strings(matches)
+	return matches
+}
+
+// globber - поиск файлов по шаблону в рабочей директории s
+type globber struct {
+	s *scope
+}
+
+// segments сопоставляет оставшиеся сегменты шаблона с содержимым директории dir
+// ("" - текущая директория)
+func (g globber) segments(dir string, segments []string) []string {
+	if len(segments) == 0 {
+		return []string{dir}
+	}
+	segment, rest := segments[0], segments[1:]
+	if segment == "" { // путь, заканчивающийся на /, соответствует только директориям
+		if len(rest) == 0 && dir != "" && isDir(g.s.path(dir)) {
+			return []string{dir + "/"}
+		}
+		return nil
+	}
+
+	if !hasMeta(segment) {
+		path := joinPath(dir, unescapePattern(segment))
+		if len(rest) == 0 {
+			if exists(g.s.path(path)) {
+				return []string{path}
+			}
+			return nil
+		}
+		return g.segments(path, rest)
+	}
+
+	var res []string
+	if segment == "**" {
+		if len(rest) == 0 {
+			return g.walk(dir)
+		}
+		// ноль директорий, затем ** от каждой вложенной директории
+		res = append(res, g.segments(dir, rest)...)
+		for _, sub := range g.listDir(dir, true) {
+			res = append(res, g.segments(sub, segments)...)
+		}
+		return res
+	}
+
+	for _, path := range g.listDir(dir, false) {
+		name := filepath.Base(path)
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(segment, ".") {
+			continue
+		}
+		if ok, err := filepath.Match(segment, name); err != nil || !ok {
+			continue
+		}
+		if len(rest) == 0 {
+			res = append(res, path)
+		} else if isDir(g.s.path(path)) {
+			res = append(res, g.segments(path, rest)...)
+		}
+	}
+	return res
+}
+
+// walk возвращает все нескрытые файлы и директории внутри dir рекурсивно
+func (g globber) walk(dir string) []string {
+	var res []string
+	for _, path := range g.listDir(dir, false) {
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			continue
+		}
+		res = append(res, path)
+		if isDir(g.s.path(path)) {
+			res = append(res, g.walk(path)...)
+		}
+	}
+	return res
+}
+
+// joinPath добавляет имя к директории, сохраняя запись директории как есть (./a, а не a)
+func joinPath(dir, name string) string {
+	switch {
+	case dir == "":
+		return name
+	case strings.HasSuffix(dir, "/"):
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+// exists сообщает, существует ли файл
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// isDir сообщает, является ли путь директорией
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// listDir возвращает пути к элементам директории; с dirsOnly - только к нескрытым директориям
+func (g globber) listDir(dir string, dirsOnly bool) []string {
+	name := dir
+	if name == "" {
+		name = "."
+	}
+	infos, err := ioutil.ReadDir(g.s.path(name))
+	if err != nil {
+		return nil
+	}
+	var res []string
+	for _, info := range infos {
+		if dirsOnly && (!info.IsDir() || strings.HasPrefix(info.Name(), ".")) {
+			continue
+		}
+		res = append(res, joinPath(dir, info.Name()))
+	}
+	return res
+}
+
+// expandBraces выполняет раскрытие фигурных скобок: a{b,c}d дает abd и acd,
+// {1..3} - 1, 2, 3, {a..c} - a, b, c. Скобки раскрываются, только если они
+// целиком находятся в одной части слова без кавычек
+func expandBraces(w word) []word {
+	for i, part := range w {
+		if part.quote != unquoted {
+			continue
+		}
+		start, end, items := findBraces(part.text)
+		if items == nil {
+			continue
+		}
+		var res []word
+		for _, item := range items {
+			variant := append(word{}, w[:i]...)
+			variant = append(variant, wordPart{text: part.text[:start] + item + part.text[end:], quote: unquoted})
+			variant = append(variant, w[i+1:]...)
+			res = append(res, expandBraces(variant)...)
+		}
+		return res
+	}
+	return []word{w}
+}
+
+// findBraces ищет в тексте первую группу {...}, подлежащую раскрытию. Возвращает ее границы
+// и варианты; nil, если такой группы нет. Подстановки ${...} пропускаются
+func findBraces(text string) (int, int, []string) {
+	for start := 0; start < len(text); start++ {
+		if text[start] != '{' || start > 0 && text[start-1] == '$' {
+			continue
+		}
+		depth := 0
+		commas := []int{}
+		for i := start; i < len(text); i++ {
+			switch text[i] {
+			case '{':
+				depth++
+			case ',':
+				if depth == 1 {
+					commas = append(commas, i)
+				}
+			case '}':
+				depth--
+			}
+			if depth > 0 {
+				continue
+			}
+			inner := text[start+1 : i]
+			if len(commas) == 0 {
+				if items := braceSequence(inner); items != nil {
+					return start, i + 1, items
+				}
+				break
+			}
+			items := []string{}
+			prev := start + 1
+			for _, comma := range append(commas, i) {
+				items = append(items, text[prev:comma])
+				prev = comma + 1
+			}
+			return start, i + 1, items
+		}
+	}
+	return 0, 0, nil
+}
+
+// braceSequence раскрывает последовательность вида 1..5 или a..e
+func braceSequence(inner string) []string {
+	parts := strings.Split(inner, "..")
+	if len(parts) != 2 {
+		return nil
+	}
+	from, errFrom := strconv.Atoi(parts[0])
+	to, errTo := strconv.Atoi(parts[1])
+	letters := errFrom != nil || errTo != nil
+	if letters {
+		if len(parts[0]) != 1 || len(parts[1]) != 1 {
+			return nil
+		}
+		from, to = int(parts[0][0]), int(parts[1][0])
+	}
+
+	step := 1
+	if from > to {
+		step = -1
+	}
+	var res []string
+	for n := from; ; n += step {
+		if letters {
+			res = append(res, string(rune(n)))
+		} else {
+			res = append(res, strconv.Itoa(n))
+		}
+		if n == to {
+			return res
+		}
+	}
+}
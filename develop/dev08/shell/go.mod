@@ -1,5 +1,3 @@
 module github.com/rixagis/wb-level-2/develop/dev08/shell
 
 go 1.17
-
-require github.com/mitchellh/go-ps v1.0.0
@@ -0,0 +1,820 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Управление заданиями. Каждый конвейер переднего плана и каждая фоновая команда (завершенная &)
+// - отдельное задание. В интерактивном шелле все внешние процессы задания помещаются в одну
+// группу процессов, и группа переднего плана получает управляющий терминал, поэтому Ctrl-Z
+// и Ctrl-C действуют на нее, а не на шелл. В скрипте управления заданиями нет: программы
+// остаются в группе шелла, а сигналы им шелл посылает по одной. Завершившихся и остановленных потомков собирает
+// один обработчик SIGCHLD (reap), остальной код только ждет изменения их состояния.
+
+// process - внешний процесс в составе задания
+type process struct {
+	pid     int
+	job     *job
+	status  int // код возврата, 128+N при завершении сигналом N
+	done    bool
+	stopped bool
+}
+
+// update обновляет состояние процесса по результату wait4
+func (p *process) update(ws syscall.WaitStatus) {
+	switch {
+	case ws.Exited():
+		p.done, p.stopped, p.status = true, false, ws.ExitStatus()
+	case ws.Signaled():
+		p.done, p.stopped, p.status = true, false, 128+int(ws.Signal())
+	case ws.Stopped():
+		p.stopped = true
+		p.job.notified = false
+	case ws.Continued():
+		p.stopped = false
+	}
+}
+
+// job - задание: конвейер переднего плана или фоновый список команд
+type job struct {
+	id         int // номер в таблице заданий, 0 - задание не в таблице
+	pgid       int // группа процессов (без управления заданиями - первый процесс), 0 - процессов еще нет
+	text       string
+	procs      []*process
+	foreground bool
+	owned      bool // команды задания выполняются в отдельной горутине (фоновое задание)
+	finished   bool // горутина фонового задания завершилась
+	status     int
+	notified   bool          // о текущем состоянии задания уже сообщено
+	started    chan struct{} // закрывается при запуске команд первого конвейера или завершении задания
+	isStarted  bool
+
+	// состояние выполнения составных команд задания (script.go)
+	flow      flow // ожидающий break, continue, return или exit
+	loops     int  // число выполняющихся циклов
+	functions int  // число выполняющихся вызовов функций
+
+	parent *job   // для этапа конвейера - задание, в группу процессов которого попадают его программы
+	sc     *scope // состояние подоболочки, в которой выполняются команды; nil - состояние шелла
+}
+
+// stage создает состояние выполнения команды конвейера, которая выполняется в шелле
+// одновременно с другими: break, return и exit внутри нее действуют только на нее,
+// как в подоболочке, а запущенные ею программы входят в задание j
+func (j *job) stage() *job {
+	return &job{
+		text:       j.text,
+		foreground: j.foreground,
+		owned:      j.owned,
+		loops:      j.loops,
+		functions:  j.functions,
+		parent:     j.group(),
+		sc:         j.sc,
+	}
+}
+
+// group возвращает задание, в которое входят процессы (для этапа конвейера - внешнее задание)
+func (j *job) group() *job {
+	if j.parent != nil {
+		return j.parent
+	}
+	return j
+}
+
+// markStarted закрывает канал started, если он еще открыт
+func (j *job) markStarted() {
+	if !j.isStarted {
+		j.isStarted = true
+		close(j.started)
+	}
+}
+
+// complete сообщает, что задание завершено
+func (j *job) complete() bool {
+	if j.owned {
+		return j.finished
+	}
+	for _, p := range j.procs {
+		if !p.done {
+			return false
+		}
+	}
+	return true
+}
+
+// updateStatus запоминает код возврата завершившегося задания: для задания из процессов
+// это код возврата последнего из них, как в wait
+func (j *job) updateStatus() {
+	if !j.owned && len(j.procs) > 0 && j.complete() {
+		j.status = j.procs[len(j.procs)-1].status
+	}
+}
+
+// isStopped сообщает, что задание остановлено: ни один процесс не выполняется, часть остановлена
+func (j *job) isStopped() bool {
+	stopped := false
+	for _, p := range j.procs {
+		if p.done {
+			continue
+		}
+		if !p.stopped {
+			return false
+		}
+		stopped = true
+	}
+	return stopped
+}
+
+// state возвращает состояние задания для вывода: Running, Stopped, Done или Exit N
+func (j *job) state() string {
+	switch {
+	case j.complete() && j.status != 0:
+		return "Exit " + strconv.Itoa(j.status)
+	case j.complete():
+		return "Done"
+	case j.isStopped():
+		return "Stopped"
+	}
+	return "Running"
+}
+
+// jobTable - таблица заданий шелла
+type jobTable struct {
+	mu      sync.Mutex
+	changed *sync.Cond // сигнализирует об изменении состояния любого процесса или задания
+	jobs    []*job     // задания в таблице, последнее - текущее (+), предпоследнее - предыдущее (-)
+	byPid   map[int]*process
+	orphans map[int]syscall.WaitStatus // состояния процессов, собранные раньше, чем они попали в таблицу
+	fg      *job                       // задание переднего плана, nil - шелл ждет ввода или выполняет встроенную команду
+	once    sync.Once
+}
+
+// jobs - задания шелла
+var jobs = newJobTable()
+
+// newJobTable создает пустую таблицу заданий
+func newJobTable() *jobTable {
+	t := &jobTable{byPid: map[int]*process{}, orphans: map[int]syscall.WaitStatus{}}
+	t.changed = sync.NewCond(&t.mu)
+	return t
+}
+
+// Состояние терминала интерактивного шелла
+var (
+	interactive  bool             // стандартный ввод шелла - терминал
+	shellPgid    int              // группа процессов шелла
+	shellTermios *syscall.Termios // настройки терминала, восстанавливаемые после заданий
+)
+
+// initJobControl включает управление заданиями, если шелл запущен на терминале:
+// помещает шелл в собственную группу процессов и делает ее активной на терминале.
+// Сигналы остановки от терминала перехватываются (а не игнорируются), чтобы
+// дочерние процессы получали их с действием по умолчанию.
+func initJobControl() {
+	if !isTerminal(0) {
+		return
+	}
+	interactive = true
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU)
+	shellPgid = os.Getpid()
+	syscall.Setpgid(0, 0)
+	tcsetpgrp(0, shellPgid)
+	shellTermios, _ = getTermios(0)
+}
+
+// init запускает сбор завершившихся потомков. Вызывается до запуска первого процесса,
+// чтобы не пропустить ни одного SIGCHLD
+func (t *jobTable) init() {
+	t.once.Do(func() {
+		sigchld := make(chan os.Signal, 1)
+		signal.Notify(sigchld, syscall.SIGCHLD)
+		go func() {
+			for range sigchld {
+				t.reap()
+			}
+		}()
+	})
+}
+
+// reap собирает состояния всех изменившихся потомков
+func (t *jobTable) reap() {
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG|syscall.WUNTRACED|syscall.WCONTINUED, nil)
+		if err != nil || pid <= 0 {
+			return
+		}
+		t.mu.Lock()
+		if p, ok := t.byPid[pid]; ok {
+			p.update(ws)
+			if p.done {
+				delete(t.byPid, pid)
+				p.job.updateStatus()
+			}
+		} else {
+			t.orphans[pid] = ws
+		}
+		t.changed.Broadcast()
+		t.mu.Unlock()
+	}
+}
+
+// newJob создает задание, не добавляя его в таблицу
+func newJob(text string, foreground bool) *job {
+	return &job{text: text, foreground: foreground, started: make(chan struct{})}
+}
+
+// pgid возвращает группу процессов задания
+func (t *jobTable) pgid(j *job) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return j.pgid
+}
+
+// isForeground сообщает, что задание выполняется на переднем плане
+func (t *jobTable) isForeground(j *job) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return j.foreground
+}
+
+// resetPgid сбрасывает группу процессов задания, если все ее процессы уже завершились
+// и к ней нельзя присоединиться
+func (t *jobTable) resetPgid(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.pgid = 0
+}
+
+// add добавляет запущенный процесс pid к заданию. Первый процесс задает группу задания
+func (t *jobTable) add(j *job, pid int) *process {
+	t.mu.Lock()
+	p := &process{pid: pid, job: j}
+	if j.pgid == 0 {
+		j.pgid = pid
+	}
+	j.procs = append(j.procs, p)
+	if ws, ok := t.orphans[pid]; ok {
+		delete(t.orphans, pid)
+		p.update(ws)
+		j.updateStatus()
+	}
+	if !p.done {
+		t.byPid[pid] = p
+	}
+	j.markStarted()
+	if j.foreground {
+		t.fg = j
+	}
+	t.mu.Unlock()
+	return p
+}
+
+// list добавляет задание в таблицу, присваивая ему номер
+func (t *jobTable) list(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listLocked(j)
+}
+
+// listLocked добавляет задание в таблицу (или делает его текущим, если оно уже там)
+func (t *jobTable) listLocked(j *job) {
+	if j.id != 0 {
+		t.removeLocked(j)
+	} else {
+		for _, other := range t.jobs {
+			if other.id >= j.id {
+				j.id = other.id + 1
+			}
+		}
+		if j.id == 0 {
+			j.id = 1
+		}
+	}
+	t.jobs = append(t.jobs, j)
+}
+
+// removeLocked убирает задание из таблицы, не сбрасывая его номер
+func (t *jobTable) removeLocked(j *job) {
+	for i, other := range t.jobs {
+		if other == j {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// start отмечает, что команды первого конвейера фонового задания запущены
+func (t *jobTable) start(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.markStarted()
+}
+
+// finish отмечает завершение фонового задания
+func (t *jobTable) finish(j *job, status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.finished = true
+	j.status = status
+	j.markStarted()
+	t.changed.Broadcast()
+}
+
+// waitProcess ждет завершения процесса. Возвращает true, если вместо этого
+// остановилось задание переднего плана, в которое входит процесс
+func (t *jobTable) waitProcess(p *process) (stopped bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for !p.done {
+		if !p.job.owned && p.job.isStopped() {
+			return true
+		}
+		t.changed.Wait()
+	}
+	return false
+}
+
+// status возвращает код возврата процесса
+func (t *jobTable) status(p *process) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return p.status
+}
+
+// waitJob ждет завершения или остановки задания, возвращает его код возврата.
+// Ожидание фонового задания (wait) прерывается по Ctrl-C и сигналам с обработчиками
+func (t *jobTable) waitJob(j *job) (status int, stopped bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for !j.complete() {
+		if j.isStopped() {
+			return 128 + int(syscall.SIGTSTP), true
+		}
+		if !j.foreground && traps.isSignaled() {
+			return 128 + int(syscall.SIGINT), false
+		}
+		t.changed.Wait()
+	}
+	j.updateStatus()
+	return j.status, false
+}
+
+// signalForeground посылает сигнал группе процессов задания переднего плана
+// и сообщает, было ли такое задание
+func (t *jobTable) signalForeground(sig syscall.Signal) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.fg == nil || t.fg.pgid == 0 {
+		return false
+	}
+	t.signalLocked(t.fg, sig)
+	return true
+}
+
+// signalLocked посылает сигнал процессам задания. С управлением заданиями сигнал получает
+// группа процессов задания; без него процессы остаются в группе шелла, и сигнал
+// посылается каждому из них
+func (t *jobTable) signalLocked(j *job, sig syscall.Signal) error {
+	if interactive {
+		return syscall.Kill(-j.pgid, sig)
+	}
+	var res error
+	for _, p := range j.procs {
+		if p.done {
+			continue
+		}
+		if err := syscall.Kill(p.pid, sig); err != nil {
+			res = err
+		}
+	}
+	return res
+}
+
+// wake будит ожидающих изменения состояния заданий, чтобы они проверили прерывание (Ctrl-C)
+func (t *jobTable) wake() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changed.Broadcast()
+}
+
+// resume продолжает остановленное задание на переднем или заднем плане
+func (t *jobTable) resume(j *job, foreground bool) error {
+	t.mu.Lock()
+	j.foreground = foreground
+	if foreground {
+		t.fg = j
+	}
+	j.notified = false
+	for _, p := range j.procs {
+		p.stopped = false
+	}
+	t.listLocked(j)
+	defer t.mu.Unlock()
+
+	if j.pgid == 0 {
+		return nil
+	}
+	if foreground && interactive {
+		tcsetpgrp(0, j.pgid)
+	}
+	return t.signalLocked(j, syscall.SIGCONT)
+}
+
+// foregroundDone возвращает терминал шеллу после завершения или остановки задания
+// переднего плана. Остановленное задание остается в таблице, завершенное удаляется
+func (t *jobTable) foregroundDone(j *job, status int, stopped bool, errOut io.Writer) int {
+	if interactive {
+		tcsetpgrp(0, shellPgid)
+		if shellTermios != nil {
+			setTermios(0, shellTermios)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.foreground = false
+	if t.fg == j {
+		t.fg = nil
+	}
+	if !stopped {
+		t.removeLocked(j)
+		return status
+	}
+	t.listLocked(j)
+	j.notified = true
+	fmt.Fprintf(errOut, "\n%s\n", t.formatLocked(j))
+	return 128 + int(syscall.SIGTSTP)
+}
+
+// formatLocked возвращает строку описания задания в формате bash:
+// "[1]+  Running                 sleep 10 &"
+func (t *jobTable) formatLocked(j *job) string {
+	mark := " "
+	switch {
+	case len(t.jobs) > 0 && t.jobs[len(t.jobs)-1] == j:
+		mark = "+"
+	case len(t.jobs) > 1 && t.jobs[len(t.jobs)-2] == j:
+		mark = "-"
+	}
+	state := j.state()
+	text := j.text
+	if state == "Running" {
+		text += " &"
+	}
+	return fmt.Sprintf("[%d]%s  %-24s%s", j.id, mark, state, text)
+}
+
+// notify сообщает о фоновых заданиях, которые завершились или остановились с момента
+// предыдущего вызова. Завершенные задания удаляются из таблицы
+func (t *jobTable) notify(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, j := range append([]*job{}, t.jobs...) {
+		switch {
+		case j.complete() && !j.foreground:
+			fmt.Fprintln(w, t.formatLocked(j))
+			t.removeLocked(j)
+		case j.isStopped() && !j.notified:
+			fmt.Fprintln(w, t.formatLocked(j))
+			j.notified = true
+		}
+	}
+}
+
+// errNoJob - ошибка поиска задания
+var errNoJob = errors.New("no such job")
+
+// find ищет задание по спецификации: %N, %+ или %% (текущее), %- (предыдущее),
+// номер процесса. Пустая спецификация означает текущее задание
+func (t *jobTable) find(spec string) (*job, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := len(t.jobs)
+	switch spec {
+	case "", "%", "%%", "%+":
+		if n == 0 {
+			return nil, errNoJob
+		}
+		return t.jobs[n-1], nil
+	case "%-":
+		if n < 2 {
+			return nil, errNoJob
+		}
+		return t.jobs[n-2], nil
+	}
+
+	if strings.HasPrefix(spec, "%") {
+		id, err := strconv.Atoi(spec[1:])
+		if err != nil {
+			return nil, errNoJob
+		}
+		for _, j := range t.jobs {
+			if j.id == id {
+				return j, nil
+			}
+		}
+		return nil, errNoJob
+	}
+
+	pid, err := strconv.Atoi(spec)
+	if err != nil {
+		return nil, errNoJob
+	}
+	for _, j := range t.jobs {
+		for _, p := range j.procs {
+			if p.pid == pid {
+				return j, nil
+			}
+		}
+	}
+	return nil, errNoJob
+}
+
+// copyMu упорядочивает чтение и запись процессов через потоки, не являющиеся файлами:
+// один буфер может быть stderr одной команды конвейера и stdout другой, а один here-документ -
+// вводом нескольких команд. Такие потоки находятся в памяти и не блокируются
+var copyMu sync.Mutex
+
+// lockedReader - поток, чтение из которого защищено copyMu
+type lockedReader struct {
+	r io.Reader
+}
+
+func (l lockedReader) Read(p []byte) (int, error) {
+	copyMu.Lock()
+	defer copyMu.Unlock()
+	return l.r.Read(p)
+}
+
+// lockedWriter - поток, запись в который защищена copyMu
+type lockedWriter struct {
+	w io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	copyMu.Lock()
+	defer copyMu.Unlock()
+	return l.w.Write(p)
+}
+
+// lockReader защищает чтение из потока в памяти с помощью copyMu
+func lockReader(r io.Reader) io.Reader {
+	switch r.(type) {
+	case *os.File, lockedReader:
+		return r
+	}
+	return lockedReader{r}
+}
+
+// lockWriter защищает запись в поток в памяти с помощью copyMu
+func lockWriter(w io.Writer) io.Writer {
+	switch w.(type) {
+	case *os.File, lockedWriter:
+		return w
+	}
+	return lockedWriter{w}
+}
+
+// startProcess запускает внешнюю программу в группе процессов задания j
+// с окружением и в рабочей директории его состояния, дополненным присваиваниями env.
+// Потоки, не являющиеся файлами (буферы, here-документы), подключаются через каналы;
+// возвращаемая группа ожидания завершается, когда весь вывод скопирован
+func startProcess(name string, args []string, env []string, std stdio, j *job) (*process, *sync.WaitGroup, error) {
+	s := j.scope()
+	j = j.group()
+	path, err := s.lookPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	copies := &sync.WaitGroup{}
+	// концы каналов, переданные потомку, в шелле больше не нужны
+	var childEnds closers
+	defer func() { childEnds.Close() }()
+
+	var files [3]*os.File
+	if f, ok := std.in.(*os.File); ok {
+		files[0] = f
+	} else {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		childEnds = append(childEnds, r)
+		files[0] = r
+		go func(in io.Reader) {
+			io.Copy(w, lockReader(in))
+			w.Close()
+		}(std.in)
+	}
+	for i, out := range []io.Writer{std.out, std.err} {
+		if f, ok := out.(*os.File); ok {
+			files[i+1] = f
+			continue
+		}
+		if i == 1 && out == std.out { // 2>&1 в буфер - общий канал для обоих потоков
+			files[2] = files[1]
+			continue
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		childEnds = append(childEnds, w)
+		files[i+1] = w
+		copies.Add(1)
+		go func(out io.Writer) {
+			io.Copy(lockWriter(out), r)
+			r.Close()
+			copies.Done()
+		}(out)
+	}
+
+	jobs.init()
+	for {
+		pgid := jobs.pgid(j)
+		// без управления заданиями (скрипт, -c) программы остаются в группе шелла:
+		// терминал принадлежит не шеллу, и в своей группе программа, читающая терминал,
+		// сразу остановилась бы по SIGTTIN
+		sys := &syscall.SysProcAttr{}
+		if interactive {
+			// первый процесс задания переднего плана сам делает свою группу активной на терминале
+			// до exec: иначе программа, сразу читающая терминал, успела бы получить SIGTTIN
+			takesTerminal := pgid == 0 && jobs.isForeground(j)
+			sys = &syscall.SysProcAttr{Setpgid: true, Pgid: pgid, Foreground: takesTerminal, Ctty: 0}
+		}
+		attr := &os.ProcAttr{
+			Dir:   s.wd,
+			Env:   s.vars.environ(env),
+			Files: files[:],
+			Sys:   sys,
+		}
+		proc, err := os.StartProcess(path, append([]string{name}, args...), attr)
+		if err != nil {
+			// группа, к которой присоединяется процесс, уже опустела - задание получает новую
+			if interactive && pgid != 0 && errors.Is(err, syscall.EPERM) {
+				jobs.resetPgid(j)
+				continue
+			}
+			return nil, nil, err
+		}
+		pid := proc.Pid
+		if interactive {
+			// группа задается и из шелла, чтобы следующие процессы задания могли сразу к ней присоединиться
+			if pgid == 0 {
+				pgid = pid
+			}
+			syscall.Setpgid(pid, pgid)
+		}
+		proc.Release()
+		return jobs.add(j, pid), copies, nil
+	}
+}
+
+// processBackground запускает список команд, завершенный &, как фоновое задание. Задание
+// выполняется в подоболочке с копией состояния задания parent: cd и присваивания в нем
+// не действуют на шелл, а его горутина не меняет рабочую директорию процесса.
+// Шелл ждет только запуска команд первого конвейера, а не их завершения: у задания
+// из одних встроенных команд нет процесса, и $! для него не меняется.
+// Стандартный ввод шелла фоновое задание не читает - вместо него подключается /dev/null
+func processBackground(a *andOr, std stdio, parent *job) {
+	j := newJob(a.text, false)
+	j.owned = true
+	j.sc = parent.scope().clone()
+	var null *os.File
+	if std.in == os.Stdin {
+		var err error
+		if null, err = os.Open(os.DevNull); err == nil {
+			std.in = null
+		}
+	}
+	jobs.list(j)
+	go func() {
+		jobs.finish(j, execAndOr(a, std, j))
+		if null != nil {
+			null.Close()
+		}
+	}()
+	<-j.started
+	pgid := jobs.pgid(j)
+	if pgid != 0 {
+		parent.scope().vars.setLastBackground(pgid)
+	}
+	switch {
+	case interactive && pgid != 0:
+		fmt.Fprintf(std.err, "[%d] %d\n", j.id, pgid)
+	case interactive:
+		fmt.Fprintf(std.err, "[%d]\n", j.id)
+	}
+}
+
+// processJobs эмулирует команду jobs: выводит список заданий, с флагом -p - только группы процессов
+func processJobs(args []string, std stdio) error {
+	pids := len(args) > 0 && args[0] == "-p"
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	for _, j := range append([]*job{}, jobs.jobs...) {
+		if pids {
+			fmt.Fprintln(std.out, j.pgid)
+			continue
+		}
+		fmt.Fprintln(std.out, jobs.formatLocked(j))
+		if j.complete() {
+			jobs.removeLocked(j)
+		} else if j.isStopped() {
+			j.notified = true
+		}
+	}
+	return nil
+}
+
+// findJob ищет задание по первому аргументу fg или bg (без аргументов - текущее)
+func findJob(args []string) (*job, error) {
+	spec := ""
+	if len(args) > 0 {
+		spec = args[0]
+	}
+	j, err := jobs.find(spec)
+	if err != nil {
+		if spec == "" {
+			spec = "current"
+		}
+		return nil, fmt.Errorf("%s: %w", spec, err)
+	}
+	return j, nil
+}
+
+// processFg эмулирует команду fg: продолжает задание на переднем плане и ждет его
+func processFg(args []string, std stdio) error {
+	j, err := findJob(args)
+	if err != nil {
+		return err
+	}
+	jobs.mu.Lock()
+	fmt.Fprintln(std.out, j.text)
+	jobs.mu.Unlock()
+	if err := jobs.resume(j, true); err != nil {
+		return err
+	}
+	status, stopped := jobs.waitJob(j)
+	return statusResult(jobs.foregroundDone(j, status, stopped, std.err))()
+}
+
+// processBg эмулирует команду bg: продолжает остановленное задание в фоне
+func processBg(args []string, std stdio) error {
+	j, err := findJob(args)
+	if err != nil {
+		return err
+	}
+	if err := jobs.resume(j, false); err != nil {
+		return err
+	}
+	jobs.mu.Lock()
+	fmt.Fprintf(std.out, "[%d]+ %s &\n", j.id, j.text)
+	jobs.mu.Unlock()
+	return nil
+}
+
+// processWait эмулирует команду wait: ждет завершения указанных заданий
+// (или всех выполняющихся), возвращает код возврата последнего
+func processWait(args []string, std stdio) error {
+	var waiting []*job
+	if len(args) == 0 {
+		jobs.mu.Lock()
+		for _, j := range jobs.jobs {
+			if !j.isStopped() {
+				waiting = append(waiting, j)
+			}
+		}
+		jobs.mu.Unlock()
+	}
+	status := 0
+	for _, arg := range args {
+		j, err := jobs.find(arg)
+		if err != nil {
+			reportError(std.err, "wait", fmt.Errorf("%s: %w", arg, err))
+			status = 127
+			continue
+		}
+		waiting = append(waiting, j)
+	}
+
+	for _, j := range waiting {
+		status, _ = jobs.waitJob(j)
+		jobs.mu.Lock()
+		if j.complete() {
+			jobs.removeLocked(j)
+		}
+		jobs.mu.Unlock()
+	}
+	return statusResult(status)()
+}
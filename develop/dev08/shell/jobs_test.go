@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// run выполняет командную строку, возвращает код возврата и вывод
+func run(t *testing.T, input string) (int, string) {
+	l, err := parse(input)
+	if err != nil {
+		t.Fatalf("testing %q, expected no parse error, got: %s", input, err)
+	}
+	out := &bytes.Buffer{}
+	status := execList(l, stdio{in: strings.NewReader(""), out: out, err: out}, nil)
+	return status, out.String()
+}
+
+func TestPipelines(t *testing.T) {
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"echo a b | cat", 0, "a b\n"},
+		{"yes | head -n 2", 0, "y\ny\n"},
+		{"true | false", 1, ""},
+		{"(echo x; sh -c 'echo y >&2') 2>&1 | sort -r", 0, "y\nx\n"},
+		{"cat <<EOF | tr a-z A-Z\nhi\nEOF\n", 0, "HI\n"},
+		{"sh -c 'exit 3' || echo $?", 0, "3\n"},
+		{"sh -c 'kill -TERM $$'", 128 + int(syscall.SIGTERM), ""},
+		{"sh -c 'echo err >&2' | tr a-z A-Z", 0, "err\n"},
+		{"sh -c 'echo err >&2' |& tr a-z A-Z", 0, "ERR\n"},
+		{"true | sh -c 'exit 2' | true; echo $PIPESTATUS", 0, "0 2 0\n"},
+		{"X=1 | cat; echo [$X]", 0, "[]\n"},
+		{"pf() { :; } | cat; type pf", 1, "myshell: type: pf: not found\n"},
+		{"while true; do echo y; done | head -n 2; echo $PIPESTATUS", 0, "y\ny\n141 0\n"},
+		{"printf 'a\\nb\\n' | while read l; do echo [$l]; done | sort -r", 0, "[b]\n[a]\n"},
+		{"echo x | { read l; echo $l$l; } | cat", 0, "xx\n"},
+		{"sleep 0.3 | { echo started; }", 0, "started\n"},
+		{"exit 3 | cat; echo $?", 0, "0\n"},
+		{"for i in 1 2; do echo $i; break | cat; done", 0, "1\n2\n"},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || output != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, output)
+		}
+	}
+}
+
+func TestBackgroundJobs(t *testing.T) {
+	if status, output := run(t, "sh -c 'exit 4' &\nwait"); status != 4 || output != "" {
+		t.Errorf("expected wait to return status of background job, got: %d %q", status, output)
+	}
+	if status, output := run(t, "BG_X=1 & cd / & wait; echo [$BG_X]; pwd | grep -c '^/$'"); status != 1 || output != "[]\n0\n" {
+		t.Errorf("expected background job not to change shell state, got: %d %q", status, output)
+	}
+
+	// задание из одних встроенных команд, ждущее ввода, не задерживает шелл
+	fifo := filepath.Join(t.TempDir(), "fifo")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	done := make(chan string, 1)
+	go func() {
+		_, output := run(t, "read BG_R < "+fifo+" & echo after")
+		done <- output
+	}()
+	select {
+	case output := <-done:
+		if output != "after\n" {
+			t.Errorf("expected shell to continue after background builtin, got: %q", output)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("background builtin blocked the shell")
+	}
+	ioutil.WriteFile(fifo, []byte("v\n"), 0600)
+	if status, output := run(t, "wait; echo [$BG_R]"); status != 0 || output != "[]\n" {
+		t.Errorf("expected background read to finish in its subshell, got: %d %q", status, output)
+	}
+
+	// стандартный ввод шелла фоновому заданию не достается
+	cmd := shellProcess("read BG_R & wait; echo done")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	defer stdin.Close()
+	cmd.Stderr = os.Stderr
+	if output, err := runProcess(cmd); err != nil || output != "done\n" {
+		t.Errorf("expected background read not to take shell input, got: %v %q", err, output)
+	}
+
+	run(t, "sleep 10 &")
+	j, err := jobs.find("%%")
+	if err != nil {
+		t.Fatalf("expected current job, got: %s", err)
+	}
+	if _, output := run(t, "jobs"); !strings.Contains(output, "Running") || !strings.Contains(output, "sleep 10 &") {
+		t.Errorf("expected running job in list, got: %q", output)
+	}
+
+	// остановка и продолжение всей группы процессов задания
+	run(t, "kill -STOP %1")
+	if _, stopped := jobs.waitJob(j); !stopped {
+		t.Errorf("expected job to be stopped")
+	}
+	if status, output := run(t, "bg"); status != 0 || output != "[1]+ sleep 10 &\n" {
+		t.Errorf("expected bg to resume job, got: %d %q", status, output)
+	}
+
+	run(t, "kill %1")
+	if status, _ := run(t, "wait %1"); status != 128+int(syscall.SIGTERM) {
+		t.Errorf("expected status of killed job, got: %d", status)
+	}
+	if _, err := jobs.find("%1"); err == nil {
+		t.Errorf("expected waited job to be removed")
+	}
+	if status, _ := run(t, "fg"); status != 1 {
+		t.Errorf("expected fg without jobs to fail, got: %d", status)
+	}
+
+	// остановленное задание переднего плана, продолжено в фоне и завершено сигналом:
+	// в списке показывается его код возврата, хотя wait для него не вызывался
+	if status, _ := run(t, "sh -c 'kill -STOP $$; sleep 10'"); status != 128+int(syscall.SIGTSTP) {
+		t.Fatalf("expected foreground job to stop, got: %d", status)
+	}
+	if j, err = jobs.find("%%"); err != nil {
+		t.Fatalf("expected current job, got: %s", err)
+	}
+	run(t, "bg; kill %%")
+	jobs.mu.Lock()
+	for !j.complete() {
+		jobs.changed.Wait()
+	}
+	jobs.mu.Unlock()
+	if _, output := run(t, "jobs"); !strings.Contains(output, "Exit 143") {
+		t.Errorf("expected status of killed job in list, got: %q", output)
+	}
+}
+
+// openPTY открывает новый псевдотерминал, возвращает его ведущую и ведомую стороны
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var unlock int32
+	var n uint32
+	if err = ioctl(int(master.Fd()), syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err == nil {
+		err = ioctl(int(master.Fd()), syscall.TIOCGPTN, unsafe.Pointer(&n))
+	}
+	if err == nil {
+		slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+// TestHelperShell - не тест, а шелл в отдельном процессе: тестовая программа, запущенная
+// командой shellProcess, выполняет скрипт из MYSHELL_TEST_SCRIPT, как myshell -c
+func TestHelperShell(t *testing.T) {
+	src, ok := os.LookupEnv("MYSHELL_TEST_SCRIPT")
+	if !ok {
+		return
+	}
+	initSignals()
+	os.Exit(runArgs([]string{"-c", src}))
+}
+
+// shellProcess возвращает команду запуска скрипта src в отдельном процессе шелла
+func shellProcess(src string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperShell$")
+	cmd.Env = append(os.Environ(), "MYSHELL_TEST_SCRIPT="+src)
+	return cmd
+}
+
+// TestScriptTerminal проверяет, что программы скрипта (-c) читают терминал, не останавливаясь
+// по SIGTTIN. Шелл запускается в новом сеансе, где псевдотерминал - управляющий
+func TestScriptTerminal(t *testing.T) {
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("pseudo-terminal not available: %s", err)
+	}
+	defer master.Close()
+	cmd := shellProcess("head -n 1; echo status=$?")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	slave.Close()
+	master.Write([]byte("line\n"))
+
+	output := make(chan string, 1)
+	go func() {
+		data, _ := ioutil.ReadAll(master) // EIO, когда все закрыли ведомую сторону
+		output <- string(data)
+	}()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected script to succeed, got: %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		t.Fatalf("script with terminal input timed out")
+	}
+	if out := strings.ReplaceAll(<-output, "\r\n", "\n"); out != "line\nline\nstatus=0\n" {
+		t.Errorf("expected program to read the terminal, got: %q", out)
+	}
+}
+
+// runProcess выполняет команду, возвращает ее вывод. Зависшая команда завершается через 10 секунд
+func runProcess(cmd *exec.Cmd) (string, error) {
+	out := &bytes.Buffer{}
+	cmd.Stdout = out
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	timer := time.AfterFunc(10*time.Second, func() { cmd.Process.Kill() })
+	defer timer.Stop()
+	err := cmd.Wait()
+	return out.String(), err
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// tokenKind - тип лексемы командной строки
+type tokenKind int
+
+const (
+	tokenEOF      tokenKind = iota
+	tokenWord               // слово (команда или аргумент)
+	tokenPipe               // |
+	tokenPipeAll            // |& - в канал направляются и stdout, и stderr
+	tokenOr                 // ||
+	tokenAmp                // &
+	tokenAnd                // &&
+	tokenSemi               // ;
+	tokenDSemi              // ;; - конец ветви case
+	tokenNewline            // перевод строки
+	tokenLParen             // (
+	tokenRParen             // )
+	tokenRedirect           // оператор перенаправления: >, >>, <, <<, >&, <&, &>, &>> (с номером дескриптора)
+)
+
+// tokenNames - обозначения лексем для сообщений об ошибках
+var tokenNames = map[tokenKind]string{
+	tokenEOF:     "end of input",
+	tokenPipe:    "|",
+	tokenPipeAll: "|&",
+	tokenOr:      "||",
+	tokenAmp:     "&",
+	tokenAnd:     "&&",
+	tokenSemi:    ";",
+	tokenDSemi:   ";;",
+	tokenNewline: "newline",
+	tokenLParen:  "(",
+	tokenRParen:  ")",
+}
+
+// heredoc - here-документ (<<DELIM); тело заполняется лексером после конца строки с оператором
+type heredoc struct {
+	delim  string
+	quoted bool // разделитель был в кавычках - тело не раскрывается
+	strip  bool // оператор <<- : у строк тела удаляются ведущие табуляции
+	body   string
+}
+
+// quoteKind - способ экранирования части слова
+type quoteKind int
+
+const (
+	unquoted     quoteKind = iota
+	singleQuoted           // '...' и символы после \ - текст берется как есть
+	doubleQuoted           // "..."
+)
+
+// wordPart - часть слова с одинаковым способом экранирования.
+// Способ экранирования сохраняется, чтобы на этапе раскрытия отличать, например, * от '*'.
+type wordPart struct {
+	text  string
+	quote quoteKind
+}
+
+// word - слово командной строки, состоящее из частей, записанных подряд без пробелов
+type word []wordPart
+
+// String возвращает текст слова без кавычек
+func (w word) String() string {
+	var builder = strings.Builder{}
+	for _, part := range w {
+		builder.WriteString(part.text)
+	}
+	return builder.String()
+}
+
+// token - лексема командной строки
+type token struct {
+	kind    tokenKind
+	word    word     // для tokenWord
+	pos     int      // позиция начала лексемы в строке
+	end     int      // позиция конца лексемы в строке
+	op      string   // для tokenRedirect - оператор без номера дескриптора
+	fd      int      // для tokenRedirect - номер дескриптора, -1 если не указан
+	heredoc *heredoc // для tokenRedirect с оператором << и <<-
+	aliases []string // псевдонимы, из значений которых получена лексема
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenWord:
+		return t.word.String()
+	case tokenRedirect:
+		if t.fd >= 0 {
+			return strconv.Itoa(t.fd) + t.op
+		}
+		return t.op
+	}
+	return tokenNames[t.kind]
+}
+
+// syntaxError - синтаксическая ошибка командной строки.
+// incomplete означает, что строка оборвалась (незакрытая кавычка, | в конце строки и т.п.)
+// и может быть продолжена следующей строкой ввода.
+type syntaxError struct {
+	msg        string
+	incomplete bool
+}
+
+func (e *syntaxError) Error() string {
+	return "syntax error: " + e.msg
+}
+
+// lexer разбивает командную строку на лексемы
+type lexer struct {
+	input   string
+	pos     int
+	tokens  []token
+	pending []*heredoc // here-документы, тела которых начинаются со следующей строки
+}
+
+// lex разбивает строку input на лексемы, последняя лексема всегда tokenEOF
+func lex(input string) ([]token, error) {
+	var l = &lexer{input: input}
+	for {
+		var t, err = l.next()
+		if err != nil {
+			return nil, err
+		}
+		t.end = l.pos
+		l.tokens = append(l.tokens, t)
+
+		// разделитель here-документа - следующее слово после оператора
+		if n := len(l.tokens); n > 1 && t.kind == tokenWord && l.tokens[n-2].heredoc != nil {
+			var doc = l.tokens[n-2].heredoc
+			doc.delim = t.word.String()
+			for _, part := range t.word {
+				doc.quoted = doc.quoted || part.quote != unquoted
+			}
+			l.pending = append(l.pending, doc)
+		}
+
+		if t.kind == tokenNewline || t.kind == tokenEOF {
+			if err := l.readHeredocs(); err != nil {
+				return nil, err
+			}
+		}
+		if t.kind == tokenEOF {
+			return l.tokens, nil
+		}
+	}
+}
+
+// readHeredocs считывает тела ожидающих here-документов, начиная с текущей позиции
+func (l *lexer) readHeredocs() error {
+	for _, doc := range l.pending {
+		var body = strings.Builder{}
+		for {
+			if l.pos >= len(l.input) {
+				return &syntaxError{msg: "here-document delimited by " + strconv.Quote(doc.delim) + " is not closed", incomplete: true}
+			}
+			var line = l.input[l.pos:]
+			var end = strings.IndexByte(line, '\n')
+			if end >= 0 {
+				line = line[:end]
+				l.pos += end + 1
+			} else {
+				l.pos = len(l.input)
+			}
+			if doc.strip {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == doc.delim {
+				break
+			}
+			body.WriteString(line)
+			body.WriteByte('\n')
+		}
+		doc.body = body.String()
+	}
+	l.pending = nil
+	return nil
+}
+
+// operators - операторы в порядке поиска: длинные проверяются раньше коротких
+var operators = []struct {
+	text string
+	kind tokenKind
+}{
+	{"&>>", tokenRedirect},
+	{"&>", tokenRedirect},
+	{"||", tokenOr},
+	{"&&", tokenAnd},
+	{"|&", tokenPipeAll},
+	{"|", tokenPipe},
+	{"&", tokenAmp},
+	{";;", tokenDSemi},
+	{";", tokenSemi},
+	{"\n", tokenNewline},
+	{"(", tokenLParen},
+	{")", tokenRParen},
+}
+
+// next считывает очередную лексему
+func (l *lexer) next() (token, error) {
+	l.skipBlanks()
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEOF, pos: l.pos}, nil
+	}
+
+	// комментарий до конца строки
+	if l.input[l.pos] == '#' {
+		for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+			l.pos++
+		}
+		return l.next()
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[l.pos:], op.text) {
+			var t = token{kind: op.kind, pos: l.pos, op: op.text, fd: -1}
+			l.pos += len(op.text)
+			return t, nil
+		}
+	}
+
+	if t, ok := l.redirect(); ok {
+		return t, nil
+	}
+
+	return l.word()
+}
+
+// redirectOperators - операторы перенаправления с необязательным номером дескриптора перед ними
+var redirectOperators = []string{"<<-", "<<", ">>", ">&", "<&", ">", "<"}
+
+// redirect считывает оператор перенаправления вида [N]op, если он начинается с текущей позиции
+func (l *lexer) redirect() (token, bool) {
+	var start = l.pos
+	var digits = start
+	for digits < len(l.input) && l.input[digits] >= '0' && l.input[digits] <= '9' {
+		digits++
+	}
+
+	for _, op := range redirectOperators {
+		if !strings.HasPrefix(l.input[digits:], op) {
+			continue
+		}
+		var t = token{kind: tokenRedirect, pos: start, op: op, fd: -1}
+		if digits > start {
+			var fd, err = strconv.Atoi(l.input[start:digits])
+			if err != nil {
+				return token{}, false
+			}
+			t.fd = fd
+		}
+		if op == "<<" || op == "<<-" {
+			t.heredoc = &heredoc{strip: op == "<<-"}
+			t.op = "<<"
+		}
+		l.pos = digits + len(op)
+		return t, true
+	}
+	return token{}, false
+}
+
+// skipBlanks пропускает пробелы и табуляции, а также экранированные переводы строк
+func (l *lexer) skipBlanks() {
+	for l.pos < len(l.input) {
+		switch {
+		case l.input[l.pos] == ' ' || l.input[l.pos] == '\t':
+			l.pos++
+		case strings.HasPrefix(l.input[l.pos:], "\\\n"):
+			l.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+// isWordBreak сообщает, заканчивает ли символ c слово без кавычек
+func isWordBreak(c byte) bool {
+	return strings.IndexByte(" \t\n|&;()<>", c) >= 0
+}
+
+// word считывает слово, состоящее из частей без кавычек, в одинарных и двойных кавычках
+func (l *lexer) word() (token, error) {
+	var t = token{kind: tokenWord, pos: l.pos}
+	var plain = strings.Builder{}
+
+	// flush переносит накопленный текст без кавычек в слово
+	var flush = func() {
+		if plain.Len() > 0 {
+			t.word = append(t.word, wordPart{text: plain.String(), quote: unquoted})
+			plain.Reset()
+		}
+	}
+
+	for l.pos < len(l.input) && !isWordBreak(l.input[l.pos]) {
+		var c = l.input[l.pos]
+		switch c {
+		case '\\':
+			if l.pos+1 >= len(l.input) {
+				return token{}, &syntaxError{msg: "unexpected end of input after \\", incomplete: true}
+			}
+			flush()
+			if l.input[l.pos+1] != '\n' { // экранированный перевод строки просто удаляется
+				t.word = append(t.word, wordPart{text: l.input[l.pos+1 : l.pos+2], quote: singleQuoted})
+			}
+			l.pos += 2
+		case '\'':
+			flush()
+			var end = strings.IndexByte(l.input[l.pos+1:], '\'')
+			if end < 0 {
+				return token{}, &syntaxError{msg: "unterminated '", incomplete: true}
+			}
+			t.word = append(t.word, wordPart{text: l.input[l.pos+1 : l.pos+1+end], quote: singleQuoted})
+			l.pos += end + 2
+		case '"':
+			flush()
+			var w, err = l.doubleQuoted(t.word)
+			if err != nil {
+				return token{}, err
+			}
+			t.word = w
+		case '$', '`':
+			var end, err = l.expansion()
+			if err != nil {
+				return token{}, err
+			}
+			plain.WriteString(l.input[l.pos:end])
+			l.pos = end
+		default:
+			plain.WriteByte(c)
+			l.pos++
+		}
+	}
+	flush()
+	return t, nil
+}
+
+// expansion возвращает конец подстановки ${...}, $(...) или `...`, начинающейся с текущей позиции,
+// чтобы пробелы и операторы внутри нее не разбивали слово. Для $ без скобок - позицию после $
+func (l *lexer) expansion() (int, error) {
+	var rest = l.input[l.pos:]
+	switch {
+	case strings.HasPrefix(rest, "${"):
+		var end = strings.IndexByte(rest, '}')
+		if end < 0 {
+			return 0, &syntaxError{msg: "unterminated ${", incomplete: true}
+		}
+		return l.pos + end + 1, nil
+	case strings.HasPrefix(rest, "$("), strings.HasPrefix(rest, "`"):
+		var end, ok = substitutionEnd(l.input, l.pos)
+		if !ok {
+			return 0, &syntaxError{msg: "unterminated command substitution", incomplete: true}
+		}
+		return end, nil
+	}
+	return l.pos + 1, nil
+}
+
+// substitutionEnd находит конец подстановки команды $(...) или `...`, начинающейся в s с позиции pos.
+// Внутри $(...) учитываются вложенные скобки, кавычки и экранирование
+func substitutionEnd(s string, pos int) (int, bool) {
+	if s[pos] == '`' {
+		for i := pos + 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '`':
+				return i + 1, true
+			}
+		}
+		return 0, false
+	}
+
+	var depth = 0
+	for i := pos + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\'':
+			var end = strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return 0, false
+			}
+			i += end + 1
+		case '"':
+			var end, ok = doubleQuoteEnd(s, i)
+			if !ok {
+				return 0, false
+			}
+			i = end - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// doubleQuoteEnd находит позицию после закрывающей двойной кавычки для кавычки в позиции pos
+func doubleQuoteEnd(s string, pos int) (int, bool) {
+	for i := pos + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"':
+			return i + 1, true
+		case s[i] == '`' || strings.HasPrefix(s[i:], "$("):
+			var end, ok = substitutionEnd(s, i)
+			if !ok {
+				return 0, false
+			}
+			i = end - 1
+		}
+	}
+	return 0, false
+}
+
+// doubleQuoted считывает содержимое двойных кавычек и добавляет его к слову w. Внутри них \
+// экранирует только символы $, `, ", \ и перевод строки, остальные обратные слэши остаются в тексте.
+// Экранированные символы добавляются отдельными частями singleQuoted, чтобы не раскрываться позже.
+func (l *lexer) doubleQuoted(w word) (word, error) {
+	var text = strings.Builder{}
+	var empty = true // "" тоже дает часть слова - пустой аргумент
+	l.pos++          // открывающая кавычка
+	for l.pos < len(l.input) {
+		var c = l.input[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			if text.Len() > 0 || empty {
+				w = append(w, wordPart{text: text.String(), quote: doubleQuoted})
+			}
+			return w, nil
+		case c == '\\' && l.pos+1 < len(l.input) && strings.IndexByte("$`\"\\\n", l.input[l.pos+1]) >= 0:
+			if text.Len() > 0 {
+				w = append(w, wordPart{text: text.String(), quote: doubleQuoted})
+				text.Reset()
+			}
+			if l.input[l.pos+1] != '\n' {
+				w = append(w, wordPart{text: l.input[l.pos+1 : l.pos+2], quote: singleQuoted})
+				empty = false
+			}
+			l.pos += 2
+		case c == '`' || strings.HasPrefix(l.input[l.pos:], "$("):
+			var end, ok = substitutionEnd(l.input, l.pos)
+			if !ok {
+				return nil, &syntaxError{msg: "unterminated command substitution", incomplete: true}
+			}
+			text.WriteString(l.input[l.pos:end])
+			empty = false
+			l.pos = end
+		default:
+			text.WriteByte(c)
+			empty = false
+			l.pos++
+		}
+	}
+	return nil, &syntaxError{msg: "unterminated \"", incomplete: true}
+}
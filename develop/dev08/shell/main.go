@@ -1,198 +1,126 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"errors"
 	"fmt"
-	"io"
-	"io/fs"
-	"log"
 	"os"
-	"os/exec"
-	"strconv"
 	"strings"
 	"syscall"
-
-	"github.com/mitchellh/go-ps"
 )
 
-// главный цикл шелла
+// главный цикл шелла; с аргументами шелл выполняет скрипт или команды -c
 func main() {
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		prompt()
-		line, _ := reader.ReadString('\n')
+	initSignals()
+	if len(os.Args) > 1 {
+		os.Exit(runArgs(os.Args[1:]))
+	}
 
-		if line == "exit\n" {
-			break
-		}
-		if line == "\n" {
-			continue
+	initJobControl()
+	reader := newLineReader()
+	std := stdio{in: os.Stdin, out: os.Stdout, err: os.Stderr}
+	if interactive {
+		loadRC(std)
+		if status, ok := vars.takeExit(); ok {
+			exitShell(status)
 		}
-		processForks(line[:len(line)-1])
 	}
-}
-
-// prompt выводит "приглашение" в начале строки
-func prompt() {
-	wd, _ := os.Getwd()
-	fmt.Print("myshell:", wd, "$ ")
-}
-
-// processForks обрабатывает вызовы fork
-func processForks(line string) {
-	forks := strings.Split(line, "&")
-	// форкаем все, кроме последнего
-	for i := 0; i < len(forks)-1; i++ {
-		ret, _, err := syscall.Syscall(syscall.SYS_FORK, 0, 0, 0)
-		if err != 0 {
-			log.Fatal(err)
+	for {
+		if interactive {
+			jobs.notify(os.Stderr)
 		}
-
-		// потомок
-		if ret == 0 {
-			fmt.Println(os.Getpid())
-			processPipes(forks[i])
-			fmt.Println("Done")
-			os.Exit(0)
+		line, err := reader.readLine(prompt())
+		if errors.Is(err, errInterrupted) { // Ctrl-C в приглашении
+			vars.setStatus(128 + int(syscall.SIGINT))
+			traps.signal("INT")
+			traps.runPending(std)
+			traps.takeInterrupted()
+			continue
 		}
-	}
-	// здесь выполняется только родитель
-	processPipes(forks[len(forks)-1])
-}
-
-// processPipes обрабатывает операторы pipe
-func processPipes(line string) {
-	commands := strings.Split(line, "|")
-	waits := []func() error{}
-	out, wait := processCommand(commands[0], os.Stdin)
-	waits = append(waits, wait)
-
-	for i := 1; i < len(commands)-1; i++ {
-		nextout, wait := processCommand(commands[i], out)
-		waits = append(waits, wait)
-		out = nextout
-	}
-
-	nextout, wait := processCommand(commands[len(commands)-1], out)
-	waits = append(waits, wait)
-	if nextout != nil {
-		_, err := io.Copy(os.Stdout, nextout)
-		if err != nil && !errors.Is(err, fs.ErrClosed) {
-			log.Fatal(err)
+		if err != nil { // Ctrl-D или конец ввода
+			exitShell(vars.lastStatus())
 		}
-	}
-	for _, wait := range waits {
-		if wait != nil {
-			wait()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		source := line + "\n"
+		commands, err := parse(source)
+		// незавершенная команда (незакрытая кавычка, here-документ) продолжается следующими строками
+		for isIncomplete(err) {
+			next, readErr := reader.readLine(prompt2())
+			if readErr != nil {
+				break
+			}
+			source += next + "\n"
+			commands, err = parse(source)
 		}
-	}
-}
-
-// processCommand обрабатывает вызов одной команды (команда + аргументы),
-// возвращает ридер для чтения результата и функцию ожидания завершения команды
-func processCommand(line string, in io.Reader) (io.Reader, func() error) {
-	args := strings.Fields(line)
-	if len(args) == 0 {
-		return nil, nil
-	}
-	switch args[0] {
-	case "pwd":
-		return getwd(), nil
-	case "cd":
-		err := os.Chdir(args[1])
 		if err != nil {
-			log.Fatal(err)
+			reportError(os.Stderr, "", err)
+			vars.setStatus(2)
+			continue
 		}
-		return nil, nil
-	case "echo":
-		str := strings.Join(args[1:], " ")
-		return echo(str), nil
-	case "ps":
-		return processPS(args[1:]), nil
-	case "kill":
-		for i := 1; i < len(args); i++ {
-			pid, err := strconv.Atoi(args[i])
-			if err != nil {
-				log.Fatal(err)
-			}
-			err = syscall.Kill(pid, syscall.SIGINT)
-			if err != nil {
-				log.Fatal(err)
-			}
+		execList(commands, std, nil)
+		traps.runPending(std)
+		if status, ok := vars.takeExit(); ok {
+			exitShell(status)
 		}
-		return nil, nil
-	default:
-		name := args[0]
-		cmdargs := []string{}
-		if len(args) > 1 {
-			cmdargs = args[1:]
+		// после Ctrl-C приглашение выводится с новой строки
+		if traps.takeInterrupted() && interactive {
+			fmt.Fprintln(os.Stderr)
 		}
-		return command(name, in, cmdargs...)
 	}
 }
 
-// getwd эмулирует команду pwd, возвращает ридер для чтения вывода
-func getwd() io.Reader {
-	buffer := bytes.Buffer{}
-	res, err := os.Getwd()
-	if err != nil {
-		log.Fatal(err)
+// exitShell выполняет обработчик EXIT и завершает шелл с кодом возврата status
+func exitShell(status int) {
+	if interactive {
+		fmt.Fprintln(os.Stderr, "exit")
 	}
-	buffer.WriteString(res + "\n")
-	return &buffer
+	os.Exit(traps.runExit(stdio{in: os.Stdin, out: os.Stdout, err: os.Stderr}, status))
 }
 
-// echo эмулирует одноименную команду, возвращает ридер для чтения вывода
-func echo(s string) io.Reader {
-	buffer := bytes.Buffer{}
-	buffer.WriteString(s + "\n")
-	return &buffer
+// isIncomplete сообщает, что ошибка разбора вызвана незавершенной командой
+func isIncomplete(err error) bool {
+	var syntaxErr *syntaxError
+	return errors.As(err, &syntaxErr) && syntaxErr.incomplete
 }
 
-// processPS эмулирует команду ps, возвращает ридер для чтения вывода
-func processPS(args []string) io.Reader {
-	var A bool
-	for _, arg := range args {
-		switch arg {
-		case "-A":
-			A = true
+// execList выполняет список команд, возвращает код возврата последней выполненной команды.
+// j - задание, в котором выполняется список; nil означает, что каждый конвейер
+// выполняется как отдельное задание переднего плана
+func execList(l *list, std stdio, j *job) int {
+	status := 0
+	for _, item := range l.items {
+		// обработчики сигналов выполняет шелл, а не команды конвейера и фоновые задания в горутинах
+		if j == nil || j.parent == nil && !j.owned {
+			traps.runPending(std)
 		}
-	}
-	output := bytes.Buffer{}
-	output.WriteString("PID\tCMD\n")
-
-	var procs []ps.Process
-	procs, err := ps.Processes()
-	if err != nil {
-		log.Fatal(err)
-	}
-	for _, p := range procs {
-		if os.Getppid() == p.Pid() || A {
-			output.WriteString(fmt.Sprintf("%d\t%s\n", p.Pid(), p.Executable()))
+		if interrupted(j) {
+			break
+		}
+		if item.background {
+			processBackground(item.andOr, std, j)
+			status = 0
+			continue
 		}
+		status = execAndOr(item.andOr, std, j)
 	}
-	return &output
+	return status
 }
 
-// command обрабатывает команду, которой нет в списке реализованных собственноручно,
-// возвращает ридер для чтения результата и функцию ожидания завершения команды
-func command(name string, in io.Reader, args ...string) (io.Reader, func() error) {
-	cmd := exec.Command(name, args...)
-	cmd.Stdin = in
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Fatal("1", err)
-	}
-
-	go func() {
-		if err := cmd.Start(); err != nil {
-			log.Fatal(err)
+// execAndOr выполняет конвейеры, связанные && и ||: следующий конвейер выполняется,
+// если предыдущий завершился успешно (для &&) или неуспешно (для ||)
+func execAndOr(a *andOr, std stdio, j *job) int {
+	v := j.scope().vars
+	status := processPipes(a.pipelines[0], std, j)
+	v.setStatus(status)
+	for i, op := range a.ops {
+		if interrupted(j) {
+			break
 		}
-		/*if err := cmd.Wait(); err != nil {
-			log.Fatal(err)
-		}*/
-	}()
-	return stdout, cmd.Wait
+		if (op == tokenAnd) == (status == 0) {
+			status = processPipes(a.pipelines[i+1], std, j)
+			v.setStatus(status)
+		}
+	}
+	return status
 }
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Синтаксическое дерево командной строки:
+//  list     - последовательность andOr, разделенных ;, & или переводом строки
+//  andOr    - конвейеры, связанные операторами && и ||
+//  pipeline - команды, связанные оператором |
+//  command  - простая команда (слова), подоболочка ( list ), группа { list; }, составная команда
+//             (if, while, for, case) или определение функции; у всех, кроме функции, могут быть перенаправления
+
+// commandNode - команда в составе конвейера
+type commandNode interface {
+	commandNode()
+}
+
+// redirect - перенаправление ввода-вывода команды
+type redirect struct {
+	fd      int    // номер перенаправляемого дескриптора, -1 - по умолчанию для оператора
+	op      string // >, >>, <, <<, >&, <&, &>, &>>
+	target  word   // имя файла, номер дескриптора для >& и <& или разделитель here-документа
+	heredoc *heredoc
+}
+
+// assignment - присваивание NAME=value перед командой
+type assignment struct {
+	name  string
+	value word
+}
+
+// simpleCommand - команда с аргументами. Присваивания без команды задают переменные шелла,
+// а перед командой - только ее окружение
+type simpleCommand struct {
+	assigns   []assignment
+	words     []word
+	redirects []redirect
+}
+
+// subshell - список команд в скобках, выполняемый в отдельном окружении
+type subshell struct {
+	body      *list
+	redirects []redirect
+}
+
+// group - список команд в фигурных скобках, выполняемый в текущем окружении
+type group struct {
+	body      *list
+	redirects []redirect
+}
+
+// ifClause - if cond; then body; [elif cond; then body;]... [else body;] fi
+type ifClause struct {
+	conds     []*list // условия if и elif
+	bodies    []*list // ветви then, соответствующие условиям
+	elseBody  *list   // nil - ветви else нет
+	redirects []redirect
+}
+
+// whileLoop - while cond; do body; done
+type whileLoop struct {
+	cond      *list
+	body      *list
+	redirects []redirect
+}
+
+// forLoop - for name [in items]; do body; done. Без in перебираются позиционные параметры
+type forLoop struct {
+	name      string
+	items     []word // nil - in не указан
+	body      *list
+	redirects []redirect
+}
+
+// caseItem - ветвь case: шаблоны, разделенные |, и список команд
+type caseItem struct {
+	patterns []word
+	body     *list
+}
+
+// caseClause - case subject in pattern) body;; ... esac
+type caseClause struct {
+	subject   word
+	items     []caseItem
+	redirects []redirect
+}
+
+// functionDef - определение функции name() body
+type functionDef struct {
+	name string
+	body commandNode
+}
+
+func (*simpleCommand) commandNode() {}
+func (*subshell) commandNode()      {}
+func (*group) commandNode()         {}
+func (*ifClause) commandNode()      {}
+func (*whileLoop) commandNode()     {}
+func (*forLoop) commandNode()       {}
+func (*caseClause) commandNode()    {}
+func (*functionDef) commandNode()   {}
+
+// pipeline - команды, связанные оператором |
+type pipeline struct {
+	commands []commandNode
+	stderr   []bool // stderr[i] - вывод ошибок commands[i] тоже направляется в канал (|&)
+	text     string // исходный текст конвейера, показывается в списке заданий
+}
+
+// andOr - конвейеры, связанные операторами && и ||.
+// ops[i] связывает pipelines[i] и pipelines[i+1].
+type andOr struct {
+	pipelines []*pipeline
+	ops       []tokenKind
+	text      string // исходный текст, показывается в списке заданий
+}
+
+// listItem - элемент списка команд; background означает, что он завершен оператором &
+type listItem struct {
+	andOr      *andOr
+	background bool
+}
+
+// list - последовательность команд
+type list struct {
+	items []listItem
+}
+
+// parser - синтаксический анализатор методом рекурсивного спуска
+type parser struct {
+	input   string
+	tokens  []token
+	pos     int
+	end     int         // позиция конца последней прочитанной лексемы
+	aliases *aliasTable // псевдонимы, раскрываемые в начале команд
+}
+
+// parse разбирает командную строку в синтаксическое дерево.
+// Пустая строка (или строка из одних комментариев) дает пустой список. Псевдонимы берутся из шелла
+func parse(input string) (*list, error) {
+	return parseAliases(input, aliases)
+}
+
+// parseAliases разбирает текст, раскрывая псевдонимы из таблицы aliases
+func parseAliases(input string, aliases *aliasTable) (*list, error) {
+	var tokens, err = lex(input)
+	if err != nil {
+		return nil, err
+	}
+	var p = &parser{input: input, tokens: tokens, aliases: aliases}
+	l, err := p.list(tokenEOF, closingWords...)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return l, nil
+}
+
+// peek возвращает текущую лексему, не продвигаясь дальше
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// advance возвращает текущую лексему и переходит к следующей
+func (p *parser) advance() token {
+	var t = p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+		p.end = t.end
+	}
+	return t
+}
+
+// skipNewlines пропускает переводы строк (допустимы после |, &&, || и внутри скобок)
+func (p *parser) skipNewlines() {
+	for p.peek().kind == tokenNewline {
+		p.advance()
+	}
+}
+
+// closingWords - зарезервированные слова, продолжающие или завершающие составную команду.
+// В начале команды они заканчивают текущий список команд
+var closingWords = []string{"then", "elif", "else", "fi", "do", "done", "esac", "}"}
+
+// isReserved сообщает, что текущая лексема - зарезервированное слово name, записанное без кавычек
+func (p *parser) isReserved(name string) bool {
+	var t = p.peek()
+	return t.kind == tokenWord && len(t.word) == 1 && t.word[0].quote == unquoted && t.word[0].text == name
+}
+
+// expect поглощает зарезервированное слово name или возвращает ошибку
+func (p *parser) expect(name string) error {
+	if !p.isReserved(name) {
+		return p.unexpected()
+	}
+	p.advance()
+	return nil
+}
+
+// unexpected возвращает ошибку о неожиданной текущей лексеме.
+// Неожиданный конец ввода означает незавершенную команду.
+func (p *parser) unexpected() error {
+	var t = p.peek()
+	if t.kind == tokenEOF {
+		return &syntaxError{msg: "unexpected end of input", incomplete: true}
+	}
+	return &syntaxError{msg: fmt.Sprintf("unexpected %q", t.String())}
+}
+
+// list разбирает список команд до лексемы end (tokenEOF, tokenRParen или tokenDSemi)
+// или до одного из зарезервированных слов words в начале команды, не поглощая их
+func (p *parser) list(end tokenKind, words ...string) (*list, error) {
+	var l = &list{}
+	for {
+		p.skipNewlines()
+		var t = p.peek()
+		if t.kind == end || t.kind == tokenEOF {
+			return l, nil
+		}
+		for _, name := range words {
+			if p.isReserved(name) {
+				return l, nil
+			}
+		}
+
+		var a, err = p.andOr()
+		if err != nil {
+			return nil, err
+		}
+		var item = listItem{andOr: a}
+
+		switch p.peek().kind {
+		case tokenAmp:
+			p.advance()
+			item.background = true
+		case tokenSemi, tokenNewline:
+			p.advance()
+		case end:
+		default:
+			return nil, p.unexpected()
+		}
+		l.items = append(l.items, item)
+	}
+}
+
+// andOr разбирает конвейеры, связанные && и ||
+func (p *parser) andOr() (*andOr, error) {
+	var start = p.peek().pos
+	var first, err = p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	var a = &andOr{pipelines: []*pipeline{first}}
+
+	for p.peek().kind == tokenAnd || p.peek().kind == tokenOr {
+		a.ops = append(a.ops, p.advance().kind)
+		p.skipNewlines()
+		next, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		a.pipelines = append(a.pipelines, next)
+	}
+	a.text = p.input[start:p.end]
+	return a, nil
+}
+
+// pipeline разбирает команды, связанные | и |&
+func (p *parser) pipeline() (*pipeline, error) {
+	var start = p.peek().pos
+	var first, err = p.command()
+	if err != nil {
+		return nil, err
+	}
+	var pl = &pipeline{commands: []commandNode{first}}
+
+	for p.peek().kind == tokenPipe || p.peek().kind == tokenPipeAll {
+		pl.stderr = append(pl.stderr, p.advance().kind == tokenPipeAll)
+		p.skipNewlines()
+		next, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		pl.commands = append(pl.commands, next)
+	}
+	pl.text = p.input[start:p.end]
+	return pl, nil
+}
+
+// command разбирает простую команду, подоболочку, составную команду или определение функции
+func (p *parser) command() (commandNode, error) {
+	p.expandAlias()
+	if p.peek().kind == tokenLParen {
+		p.advance()
+		var body, err = p.list(tokenRParen)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRParen {
+			return nil, p.unexpected()
+		}
+		p.advance()
+		if len(body.items) == 0 {
+			return nil, &syntaxError{msg: "empty subshell"}
+		}
+		var s = &subshell{body: body}
+		s.redirects, err = p.redirects()
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	switch {
+	case p.isReserved("{"):
+		return p.group()
+	case p.isReserved("if"):
+		return p.ifClause()
+	case p.isReserved("while"):
+		return p.whileLoop()
+	case p.isReserved("for"):
+		return p.forLoop()
+	case p.isReserved("case"):
+		return p.caseClause()
+	case p.isReserved("function"):
+		p.advance()
+		return p.function()
+	}
+	for _, name := range closingWords {
+		if p.isReserved(name) {
+			return nil, p.unexpected()
+		}
+	}
+	if p.peek().kind == tokenWord && p.tokens[p.pos+1].kind == tokenLParen {
+		return p.function()
+	}
+
+	var c = &simpleCommand{}
+	for {
+		switch p.peek().kind {
+		case tokenWord:
+			var w = p.advance().word
+			if a, ok := parseAssignment(w); ok && len(c.words) == 0 {
+				c.assigns = append(c.assigns, a)
+				continue
+			}
+			c.words = append(c.words, w)
+			continue
+		case tokenRedirect:
+			var r, err = p.redirect()
+			if err != nil {
+				return nil, err
+			}
+			c.redirects = append(c.redirects, r)
+			continue
+		}
+		break
+	}
+	if len(c.assigns) == 0 && len(c.words) == 0 && len(c.redirects) == 0 {
+		return nil, p.unexpected()
+	}
+	return c, nil
+}
+
+// expandAlias заменяет слово без кавычек в начале команды значением псевдонима (alias).
+// Лексемы значения получают позицию замененного слова, а сам псевдоним
+// не раскрывается повторно внутри своего значения
+func (p *parser) expandAlias() {
+	for {
+		var t = p.peek()
+		if t.kind != tokenWord || len(t.word) != 1 || t.word[0].quote != unquoted {
+			return
+		}
+		var name = t.word[0].text
+		var value, ok = p.aliases.get(name)
+		if !ok {
+			return
+		}
+		for _, used := range t.aliases {
+			if used == name {
+				return
+			}
+		}
+		var tokens, err = lex(value)
+		if err != nil {
+			return
+		}
+		tokens = tokens[:len(tokens)-1] // без tokenEOF
+		for i := range tokens {
+			tokens[i].pos, tokens[i].end = t.pos, t.end
+			tokens[i].aliases = append(append([]string{}, t.aliases...), name)
+		}
+		var rest = append(tokens, p.tokens[p.pos+1:]...)
+		p.tokens = append(p.tokens[:p.pos:p.pos], rest...)
+	}
+}
+
+// redirect разбирает оператор перенаправления и следующее за ним слово
+func (p *parser) redirect() (redirect, error) {
+	var op = p.advance()
+	if p.peek().kind != tokenWord {
+		return redirect{}, p.unexpected()
+	}
+	return redirect{fd: op.fd, op: op.op, target: p.advance().word, heredoc: op.heredoc}, nil
+}
+
+// redirects разбирает перенаправления после составной команды
+func (p *parser) redirects() ([]redirect, error) {
+	var res []redirect
+	for p.peek().kind == tokenRedirect {
+		var r, err = p.redirect()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// body разбирает непустой список команд до одного из зарезервированных слов words
+func (p *parser) body(words ...string) (*list, error) {
+	var l, err = p.list(tokenEOF, words...)
+	if err != nil {
+		return nil, err
+	}
+	if len(l.items) == 0 {
+		return nil, p.unexpected()
+	}
+	return l, nil
+}
+
+// group разбирает { list; }
+func (p *parser) group() (commandNode, error) {
+	p.advance()
+	var body, err = p.body("}")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	var g = &group{body: body}
+	g.redirects, err = p.redirects()
+	return g, err
+}
+
+// ifClause разбирает if ... then ... [elif ... then ...]... [else ...] fi
+func (p *parser) ifClause() (commandNode, error) {
+	var c = &ifClause{}
+	for p.isReserved("if") || p.isReserved("elif") {
+		p.advance()
+		var cond, err = p.body("then")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.body("elif", "else", "fi")
+		if err != nil {
+			return nil, err
+		}
+		c.conds = append(c.conds, cond)
+		c.bodies = append(c.bodies, body)
+	}
+	if p.isReserved("else") {
+		p.advance()
+		var body, err = p.body("fi")
+		if err != nil {
+			return nil, err
+		}
+		c.elseBody = body
+	}
+	if err := p.expect("fi"); err != nil {
+		return nil, err
+	}
+	var err error
+	c.redirects, err = p.redirects()
+	return c, err
+}
+
+// loopBody разбирает do list; done
+func (p *parser) loopBody() (*list, error) {
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	var body, err = p.body("done")
+	if err != nil {
+		return nil, err
+	}
+	return body, p.expect("done")
+}
+
+// whileLoop разбирает while list; do list; done
+func (p *parser) whileLoop() (commandNode, error) {
+	p.advance()
+	var cond, err = p.body("do")
+	if err != nil {
+		return nil, err
+	}
+	var w = &whileLoop{cond: cond}
+	if w.body, err = p.loopBody(); err != nil {
+		return nil, err
+	}
+	w.redirects, err = p.redirects()
+	return w, err
+}
+
+// forLoop разбирает for name [in word...]; do list; done
+func (p *parser) forLoop() (commandNode, error) {
+	p.advance()
+	var t = p.peek()
+	if t.kind != tokenWord || !isName(t.word.String()) || len(t.word) != 1 || t.word[0].quote != unquoted {
+		return nil, p.unexpected()
+	}
+	p.advance()
+	var f = &forLoop{name: t.word.String()}
+	p.skipNewlines()
+	if p.isReserved("in") {
+		p.advance()
+		f.items = []word{}
+		for p.peek().kind == tokenWord {
+			f.items = append(f.items, p.advance().word)
+		}
+		if p.peek().kind != tokenSemi && p.peek().kind != tokenNewline {
+			return nil, p.unexpected()
+		}
+		p.advance()
+	} else if p.peek().kind == tokenSemi {
+		p.advance()
+	}
+	p.skipNewlines()
+
+	var err error
+	if f.body, err = p.loopBody(); err != nil {
+		return nil, err
+	}
+	f.redirects, err = p.redirects()
+	return f, err
+}
+
+// caseClause разбирает case word in [(]pattern[|pattern]...) list;; ... esac.
+// После последней ветви ;; можно не указывать
+func (p *parser) caseClause() (commandNode, error) {
+	p.advance()
+	if p.peek().kind != tokenWord {
+		return nil, p.unexpected()
+	}
+	var c = &caseClause{subject: p.advance().word}
+	p.skipNewlines()
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipNewlines()
+		if p.isReserved("esac") {
+			break
+		}
+		if p.peek().kind == tokenLParen {
+			p.advance()
+		}
+		var item = caseItem{}
+		for {
+			if p.peek().kind != tokenWord {
+				return nil, p.unexpected()
+			}
+			item.patterns = append(item.patterns, p.advance().word)
+			if p.peek().kind != tokenPipe {
+				break
+			}
+			p.advance()
+		}
+		if p.peek().kind != tokenRParen {
+			return nil, p.unexpected()
+		}
+		p.advance()
+
+		var body, err = p.list(tokenDSemi, "esac")
+		if err != nil {
+			return nil, err
+		}
+		item.body = body
+		c.items = append(c.items, item)
+		if p.peek().kind != tokenDSemi {
+			break
+		}
+		p.advance()
+	}
+	p.skipNewlines()
+	if err := p.expect("esac"); err != nil {
+		return nil, err
+	}
+	var err error
+	c.redirects, err = p.redirects()
+	return c, err
+}
+
+// function разбирает определение функции name() compound-command; после слова function
+// скобки можно не указывать
+func (p *parser) function() (commandNode, error) {
+	var t = p.peek()
+	if t.kind != tokenWord || len(t.word) != 1 || t.word[0].quote != unquoted {
+		return nil, p.unexpected()
+	}
+	p.advance()
+	if p.peek().kind == tokenLParen {
+		p.advance()
+		if p.peek().kind != tokenRParen {
+			return nil, p.unexpected()
+		}
+		p.advance()
+	}
+	p.skipNewlines()
+
+	var body, err = p.command()
+	if err != nil {
+		return nil, err
+	}
+	switch body.(type) {
+	case *simpleCommand, *functionDef:
+		return nil, &syntaxError{msg: "function body must be a compound command"}
+	}
+	return &functionDef{name: t.word.String(), body: body}, nil
+}
+
+// parseAssignment распознает слово вида NAME=value, где NAME записано без кавычек
+func parseAssignment(w word) (assignment, bool) {
+	if len(w) == 0 || w[0].quote != unquoted {
+		return assignment{}, false
+	}
+	var i = strings.IndexByte(w[0].text, '=')
+	if i <= 0 || !isName(w[0].text[:i]) {
+		return assignment{}, false
+	}
+	var value = word{}
+	if rest := w[0].text[i+1:]; rest != "" {
+		value = append(value, wordPart{text: rest, quote: unquoted})
+	}
+	value = append(value, w[1:]...)
+	return assignment{name: w[0].text[:i], value: value}, true
+}
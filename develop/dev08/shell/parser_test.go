@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// words возвращает текст слов простой команды
+func words(c commandNode) []string {
+	simple, ok := c.(*simpleCommand)
+	if !ok {
+		return nil
+	}
+	res := []string{}
+	for _, w := range simple.words {
+		res = append(res, w.String())
+	}
+	return res
+}
+
+func TestLexWords(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{`echo a   b`, []string{"echo", "a", "b"}},
+		{`echo "a | b"`, []string{"echo", "a | b"}},
+		{`grep 'x y' file`, []string{"grep", "x y", "file"}},
+		{`echo a\ b`, []string{"echo", "a b"}},
+		{`echo "a\"b" 'c\d'`, []string{"echo", `a"b`, `c\d`}},
+		{`echo "x\ny"`, []string{"echo", `x\ny`}},
+		{`echo pre"mid"'post'`, []string{"echo", "premidpost"}},
+		{`echo "" ''`, []string{"echo", "", ""}},
+		{`echo a#b # comment`, []string{"echo", "a#b"}},
+	}
+
+	for _, testCase := range testCases {
+		tokens, err := lex(testCase.input)
+		if err != nil {
+			t.Errorf("testing %q, expected no error, got: %s", testCase.input, err)
+			continue
+		}
+		res := []string{}
+		for _, tok := range tokens {
+			if tok.kind == tokenWord {
+				res = append(res, tok.word.String())
+			}
+		}
+		if !reflect.DeepEqual(res, testCase.expected) {
+			t.Errorf("testing %q, expected: %q, got: %q", testCase.input, testCase.expected, res)
+		}
+	}
+}
+
+func TestLexQuoting(t *testing.T) {
+	tokens, err := lex(`a*"b*"\*`)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	expected := word{{"a*", unquoted}, {"b*", doubleQuoted}, {"*", singleQuoted}}
+	if !reflect.DeepEqual(tokens[0].word, expected) {
+		t.Errorf("expected: %v, got: %v", expected, tokens[0].word)
+	}
+}
+
+func TestParse(t *testing.T) {
+	l, err := parse("cd /tmp && ls | grep 'a b' || echo fail; sleep 1 &\n(pwd; echo x) | cat")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if len(l.items) != 3 {
+		t.Fatalf("expected 3 list items, got: %d", len(l.items))
+	}
+
+	first := l.items[0].andOr
+	if !reflect.DeepEqual(first.ops, []tokenKind{tokenAnd, tokenOr}) {
+		t.Errorf("expected && and ||, got: %v", first.ops)
+	}
+	if got := words(first.pipelines[1].commands[1]); !reflect.DeepEqual(got, []string{"grep", "a b"}) {
+		t.Errorf("expected grep 'a b', got: %q", got)
+	}
+
+	if l.items[0].background || !l.items[1].background || l.items[2].background {
+		t.Errorf("expected only the second item to run in background")
+	}
+
+	pl := l.items[2].andOr.pipelines[0]
+	sub, ok := pl.commands[0].(*subshell)
+	if !ok {
+		t.Fatalf("expected subshell, got: %T", pl.commands[0])
+	}
+	if len(sub.body.items) != 2 {
+		t.Errorf("expected 2 commands in subshell, got: %d", len(sub.body.items))
+	}
+
+	l, err = parse("a |& b | c")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if got := l.items[0].andOr.pipelines[0].stderr; !reflect.DeepEqual(got, []bool{true, false}) {
+		t.Errorf("expected |& only after the first command, got: %v", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"| ls", false},
+		{"ls ||", true},
+		{"ls && && pwd", false},
+		{"(ls", true},
+		{"ls)", false},
+		{"()", false},
+		{"echo 'abc", true},
+		{`echo "abc`, true},
+		{"echo abc\\", true},
+		{"ls ;; pwd", false},
+	}
+
+	for _, testCase := range testCases {
+		_, err := parse(testCase.input)
+		var syntaxErr *syntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("testing %q, expected syntax error, got: %v", testCase.input, err)
+			continue
+		}
+		if syntaxErr.incomplete != testCase.incomplete {
+			t.Errorf("testing %q, expected incomplete: %v, got: %v", testCase.input, testCase.incomplete, syntaxErr.incomplete)
+		}
+	}
+
+	l, err := parse("  # only a comment\n\n")
+	if err != nil || len(l.items) != 0 {
+		t.Errorf("expected empty list for comment, got: %v, %v", l, err)
+	}
+}
+
+func TestParseRedirects(t *testing.T) {
+	l, err := parse("cmd arg >out 2>&1 <in 2>>log &>all\ncat <<'EOF' | sort\n\tline $x\nEOF\n")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+
+	c := l.items[0].andOr.pipelines[0].commands[0].(*simpleCommand)
+	if got := words(c); !reflect.DeepEqual(got, []string{"cmd", "arg"}) {
+		t.Errorf("expected words cmd arg, got: %q", got)
+	}
+	expected := []struct {
+		fd     int
+		op     string
+		target string
+	}{
+		{-1, ">", "out"},
+		{2, ">&", "1"},
+		{-1, "<", "in"},
+		{2, ">>", "log"},
+		{-1, "&>", "all"},
+	}
+	if len(c.redirects) != len(expected) {
+		t.Fatalf("expected %d redirects, got: %d", len(expected), len(c.redirects))
+	}
+	for i, r := range c.redirects {
+		if r.fd != expected[i].fd || r.op != expected[i].op || r.target.String() != expected[i].target {
+			t.Errorf("redirect %d, expected: %v, got: %d %s %s", i, expected[i], r.fd, r.op, r.target.String())
+		}
+	}
+
+	doc := l.items[1].andOr.pipelines[0].commands[0].(*simpleCommand).redirects[0].heredoc
+	if doc == nil || doc.body != "\tline $x\n" || !doc.quoted || doc.delim != "EOF" {
+		t.Errorf("unexpected here-document: %+v", doc)
+	}
+
+	if _, err := parse("cat <<EOF\nno end\n"); !isIncomplete(err) {
+		t.Errorf("expected incomplete here-document, got: %v", err)
+	}
+}
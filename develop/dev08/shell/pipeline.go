@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Конвейеры. Команды конвейера соединяются каналами os.Pipe и запускаются все сразу,
+// и только потом шелл ждет их завершения. Внешние программы выполняются отдельными процессами,
+// а встроенные и составные команды - в горутинах шелла со своим состоянием выполнения
+// (job.stage), поэтому, например, цикл в середине конвейера читает вывод предыдущей команды
+// по мере его появления. Как и в bash, каждая команда конвейера из нескольких команд
+// выполняется в подоболочке (job.subshell): cd, присваивания и определения функций в ней
+// не меняют состояние шелла. stderr идет в канал только для |&.
+
+// command - команда конвейера: встроенная, функция, составная команда или внешняя программа
+type command interface {
+	// start запускает команду с потоками std, не дожидаясь ее завершения. pipes - концы каналов
+	// конвейера из std: команда закрывает их, как только они ей больше не нужны
+	start(std stdio, pipes closers)
+	// wait ждет завершения команды; код возврата передается как statusError
+	wait() error
+}
+
+// shellCommand - команда, которую выполняет сам шелл (встроенная, функция или составная).
+// Выполняется в горутине, чтобы не задерживать запуск следующих команд конвейера
+type shellCommand struct {
+	run       func(std stdio) int
+	redirects []redirect
+	j         *job // задание, в состоянии которого раскрываются перенаправления
+	done      chan struct{}
+	status    int
+}
+
+func (c *shellCommand) start(std stdio, pipes closers) {
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		defer pipes.Close()
+		std, files, err := applyRedirects(c.redirects, std, c.j)
+		if err != nil {
+			reportError(std.err, "", err)
+			c.status = 1
+			return
+		}
+		defer files.Close()
+		c.status = c.run(std)
+	}()
+}
+
+func (c *shellCommand) wait() error {
+	<-c.done
+	return statusResult(c.status)()
+}
+
+// externalCommand - внешняя программа, запускаемая в группе процессов задания j
+// с окружением шелла, дополненным присваиваниями env
+type externalCommand struct {
+	name      string
+	args      []string
+	env       []string
+	redirects []redirect
+	j         *job
+	proc      *process
+	copies    *sync.WaitGroup
+	status    int // код возврата, если программу не удалось запустить
+}
+
+func (c *externalCommand) start(std stdio, pipes closers) {
+	// копии каналов остаются только у потомка: если следующая команда завершится раньше,
+	// программа получит SIGPIPE, а чтение ее вывода закончится с ее завершением
+	defer pipes.Close()
+	std, files, err := applyRedirects(c.redirects, std, c.j)
+	if err != nil {
+		reportError(std.err, "", err)
+		c.status = 1
+		return
+	}
+	defer files.Close()
+
+	c.proc, c.copies, err = startProcess(c.name, c.args, c.env, std, c.j)
+	if err != nil {
+		reportError(std.err, c.name, err)
+		// как в bash: 127 - команда не найдена, 126 - найдена, но не запускается
+		c.status = 126
+		if errors.Is(err, errNotFound) || errors.Is(err, fs.ErrNotExist) {
+			c.status = 127
+		}
+	}
+}
+
+func (c *externalCommand) wait() error {
+	if c.proc == nil {
+		return statusResult(c.status)()
+	}
+	if jobs.waitProcess(c.proc) {
+		return errStopped
+	}
+	c.copies.Wait()
+	status := jobs.status(c.proc)
+	// Ctrl-C получила только группа переднего плана, но прервать нужно всю строку, как в bash
+	if status == 128+int(syscall.SIGINT) && interactive && !c.j.owned {
+		traps.interrupt()
+	}
+	return statusResult(status)()
+}
+
+// runCommand запускает команду и ждет ее завершения, возвращает код возврата
+func runCommand(c command, std stdio) int {
+	c.start(std, nil)
+	return exitCode(c.wait())
+}
+
+// processPipes выполняет конвейер, возвращает код возврата последней команды
+// (с set -o pipefail - последней из завершившихся неуспешно). Коды всех команд
+// сохраняются в PIPESTATUS через пробел
+func processPipes(pl *pipeline, std stdio, j *job) int {
+	own := j == nil
+	if own {
+		j = newJob(pl.text, true)
+	}
+
+	n := len(pl.commands)
+	if n > 1 { // команды выполняются одновременно: общие потоки в памяти нужно защитить
+		std = stdio{in: lockReader(std.in), out: lockWriter(std.out), err: lockWriter(std.err)}
+	}
+	commands := make([]command, 0, n)
+	failed := false
+	in := std.in
+	for i, c := range pl.commands {
+		stage := stdio{in: in, out: std.out, err: std.err}
+		var pipes closers
+		if i > 0 {
+			pipes = append(pipes, in.(*os.File))
+		}
+		if i < n-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				reportError(std.err, "", err)
+				pipes.Close()
+				failed = true
+				break
+			}
+			stage.out, in = w, r
+			pipes = append(pipes, w)
+			if pl.stderr[i] {
+				stage.err = w
+			}
+		}
+		stageJob := j
+		if n > 1 {
+			stageJob = j.subshell()
+		}
+		cmd := processCommand(c, stage, stageJob)
+		cmd.start(stage, pipes)
+		commands = append(commands, cmd)
+	}
+	if j.owned && j.parent == nil {
+		// фоновое задание запущено, шелл может не ждать дальше (processBackground)
+		jobs.start(j)
+	}
+
+	pipefail := j.scope().vars.option("pipefail")
+	status := 0
+	stopped := false
+	codes := make([]string, len(commands))
+	for i, c := range commands {
+		err := c.wait()
+		stopped = stopped || errors.Is(err, errStopped)
+		code := exitCode(err)
+		codes[i] = strconv.Itoa(code)
+		if code != 0 || !pipefail {
+			status = code
+		}
+	}
+	if failed {
+		status = 1
+	}
+	if j.parent == nil {
+		j.scope().vars.set("PIPESTATUS", strings.Join(codes, " "))
+	}
+	if own {
+		status = jobs.foregroundDone(j, status, stopped, std.err)
+		if j.flow.kind == flowExit {
+			vars.requestExit(j.flow.status)
+		}
+	}
+	return status
+}
+
+// processCommand создает команду конвейера из узла разбора (простой, составной команды
+// или подоболочки); простая команда при этом раскрывается
+func processCommand(c commandNode, std stdio, j *job) command {
+	switch c := c.(type) {
+	case *subshell:
+		return processSubshell(c, j)
+	case *group:
+		return &shellCommand{run: func(std stdio) int { return execList(c.body, std, j) }, redirects: c.redirects, j: j}
+	case *ifClause:
+		return &shellCommand{run: func(std stdio) int { return execIf(c, std, j) }, redirects: c.redirects, j: j}
+	case *whileLoop:
+		return &shellCommand{run: func(std stdio) int { return execWhile(c, std, j) }, redirects: c.redirects, j: j}
+	case *forLoop:
+		return &shellCommand{run: func(std stdio) int { return execFor(c, std, j) }, redirects: c.redirects, j: j}
+	case *caseClause:
+		return &shellCommand{run: func(std stdio) int { return execCase(c, std, j) }, redirects: c.redirects, j: j}
+	case *functionDef:
+		return &shellCommand{run: func(stdio) int {
+			j.scope().functions.set(c)
+			return 0
+		}}
+	case *simpleCommand:
+		e := newExpander(std, j)
+		assigns := make([]string, len(c.assigns))
+		for i, a := range c.assigns {
+			assigns[i] = a.name + "=" + e.single(a.value)
+		}
+		return processSimpleCommand(e.words(c.words), assigns, c.redirects, j)
+	}
+	return &shellCommand{run: func(stdio) int { return 0 }}
+}
+
+// processSubshell выполняет список команд в скобках с копией состояния шелла: переменные,
+// функции, псевдонимы, обработчики сигналов, параметры set -o и рабочая директория,
+// измененные внутри подоболочки, не меняются в шелле, exit завершает только подоболочку.
+// При завершении подоболочка, как и шелл, выполняет свой обработчик EXIT
+func processSubshell(s *subshell, j *job) command {
+	sub := j.subshell()
+	return &shellCommand{run: func(std stdio) int {
+		status := execList(s.body, std, sub)
+		if sub.flow.kind == flowExit {
+			status = sub.flow.status
+		}
+		sub.flow = flow{}
+		sub.scope().vars.setStatus(status)
+		sub.scope().traps.run("EXIT", std, sub)
+		if sub.flow.kind == flowExit {
+			status = sub.flow.status
+		}
+		return status
+	}, redirects: s.redirects, j: j}
+}
+
+// processSimpleCommand создает команду из присваиваний, команды с аргументами и перенаправлений.
+// Присваивания вида NAME=value без команды задают переменные шелла, а перед внешней командой
+// попадают только в ее окружение
+func processSimpleCommand(args []string, assigns []string, redirects []redirect, j *job) command {
+	if len(args) == 0 {
+		// команда из одних перенаправлений только открывает (и создает) файлы
+		return &shellCommand{run: func(stdio) int {
+			for _, kv := range assigns {
+				i := strings.IndexByte(kv, '=')
+				j.scope().vars.set(kv[:i], kv[i+1:])
+			}
+			return 0
+		}, redirects: redirects, j: j}
+	}
+	if f := j.scope().functions.get(args[0]); f != nil {
+		return &shellCommand{run: func(std stdio) int { return callFunction(f, args, std, j) }, redirects: redirects, j: j}
+	}
+	if !isBuiltin(args[0]) {
+		return &externalCommand{name: args[0], args: args[1:], env: assigns, redirects: redirects, j: j}
+	}
+	return &shellCommand{run: func(std stdio) int { return runBuiltin(args, std, j) }, redirects: redirects, j: j}
+}
+
+// runBuiltin выполняет встроенную команду, возвращает ее код возврата
+func runBuiltin(args []string, std stdio, j *job) int {
+	err := builtins[args[0]](args[1:], std, j)
+	// следующая команда конвейера завершилась: этап, как процесс после SIGPIPE, прекращает работу
+	if errors.Is(err, syscall.EPIPE) && j.parent != nil {
+		j.flow = flow{kind: flowExit, status: 128 + int(syscall.SIGPIPE)}
+		return 128 + int(syscall.SIGPIPE)
+	}
+	var status statusError
+	if err != nil && !errors.As(err, &status) {
+		reportError(std.err, args[0], err)
+	}
+	return exitCode(err)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Приглашения шелла задаются переменными PS1 (перед командой) и PS2 (продолжение
+// незавершенной команды) с экранированием в стиле bash:
+//
+//	\u - имя пользователя, \h - имя хоста до первой точки, \H - полное имя хоста,
+//	\w - рабочая директория (домашняя - ~), \W - ее последний элемент,
+//	\$ - # для root, иначе $, \t - время ЧЧ:ММ:СС, \A - время ЧЧ:ММ, \d - дата,
+//	\? - код возврата последней команды, \g - ветка git рабочей директории,
+//	\e - символ ESC для цветов ANSI (\e[32m), \NNN - символ с восьмеричным кодом,
+//	\n - перевод строки, \\ - обратная косая черта,
+//	\[ и \] - границы непечатаемых последовательностей (для совместимости с bash, опускаются)
+
+// defaultPS1 и defaultPS2 - приглашения, если PS1 и PS2 не заданы
+const (
+	defaultPS1 = `myshell:\w\$ `
+	defaultPS2 = "> "
+)
+
+// prompt возвращает "приглашение" в начале строки
+func prompt() string {
+	return promptVar("PS1", defaultPS1)
+}
+
+// prompt2 возвращает приглашение для продолжения незавершенной команды
+func prompt2() string {
+	return promptVar("PS2", defaultPS2)
+}
+
+// promptVar раскрывает приглашение из переменной name или def, если она не задана
+func promptVar(name, def string) string {
+	ps, ok := vars.get(name)
+	if !ok {
+		ps = def
+	}
+	return expandPrompt(ps)
+}
+
+// expandPrompt заменяет экранированные последовательности приглашения
+func expandPrompt(ps string) string {
+	builder := strings.Builder{}
+	for i := 0; i < len(ps); i++ {
+		if ps[i] != '\\' || i+1 == len(ps) {
+			builder.WriteByte(ps[i])
+			continue
+		}
+		i++
+		switch c := ps[i]; c {
+		case 'u':
+			builder.WriteString(userName())
+		case 'h', 'H':
+			host, _ := os.Hostname()
+			if j := strings.IndexByte(host, '.'); j >= 0 && c == 'h' {
+				host = host[:j]
+			}
+			builder.WriteString(host)
+		case 'w', 'W':
+			wd, _ := os.Getwd()
+			dir := shortDir(wd, shell)
+			if c == 'W' && dir != "~" && dir != "/" {
+				dir = filepath.Base(dir)
+			}
+			builder.WriteString(dir)
+		case '$':
+			if os.Geteuid() == 0 {
+				builder.WriteByte('#')
+			} else {
+				builder.WriteByte('$')
+			}
+		case 't':
+			builder.WriteString(time.Now().Format("15:04:05"))
+		case 'A':
+			builder.WriteString(time.Now().Format("15:04"))
+		case 'd':
+			builder.WriteString(time.Now().Format("Mon Jan 02"))
+		case '?':
+			builder.WriteString(strconv.Itoa(vars.lastStatus()))
+		case 'g':
+			wd, _ := os.Getwd()
+			builder.WriteString(gitBranch(wd))
+		case 'e':
+			builder.WriteByte('\x1b')
+		case 'a':
+			builder.WriteByte('\a')
+		case 'n':
+			builder.WriteByte('\n')
+		case '\\':
+			builder.WriteByte('\\')
+		case '[', ']':
+		case '0', '1', '2', '3':
+			j := i
+			for j < len(ps) && j < i+3 && ps[j] >= '0' && ps[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseUint(ps[i:j], 8, 8)
+			builder.WriteByte(byte(n))
+			i = j - 1
+		default:
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String()
+}
+
+// userName возвращает имя текущего пользователя
+func userName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	name, _ := vars.get("USER")
+	return name
+}
+
+// gitBranch возвращает ветку git-репозитория, в который входит директория dir:
+// имя ветки из .git/HEAD или сокращенный хеш коммита, если HEAD отсоединен.
+// Вне репозитория возвращает пустую строку
+func gitBranch(dir string) string {
+	for {
+		git := filepath.Join(dir, ".git")
+		if info, err := os.Stat(git); err == nil {
+			if !info.IsDir() {
+				// рабочее дерево (git worktree) или подмодуль: .git - файл со ссылкой "gitdir: путь"
+				data, err := ioutil.ReadFile(git)
+				if err != nil {
+					return ""
+				}
+				git = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(git) {
+					git = filepath.Join(dir, git)
+				}
+			}
+			data, err := ioutil.ReadFile(filepath.Join(git, "HEAD"))
+			if err != nil {
+				return ""
+			}
+			head := strings.TrimSpace(string(data))
+			if strings.HasPrefix(head, "ref: ") {
+				return strings.TrimPrefix(strings.TrimPrefix(head, "ref: "), "refs/heads/")
+			}
+			if len(head) > 7 {
+				head = head[:7]
+			}
+			return head
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestExpandPrompt(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	home, _ := vars.get("HOME")
+	defer vars.set("HOME", home)
+	vars.set("HOME", "/tmp")
+	os.Chdir("/tmp")
+	vars.setStatus(3)
+	defer vars.setStatus(0)
+
+	sign := "$"
+	if os.Geteuid() == 0 {
+		sign = "#"
+	}
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`\w:\W\$ `, "~:~" + sign + " "},
+		{`[\?]`, "[3]"},
+		{`\e[1;32m\[\033[0m\]`, "\x1b[1;32m\x1b[0m"},
+		{`a\nb\\c\x`, "a\nb\\c\\x"},
+		{`\t`, `^\d\d:\d\d:\d\d$`},
+		{`\u@\h`, `^.+@[^.]+$`},
+		{`end\`, `end\`},
+	}
+
+	for _, testCase := range testCases {
+		res := expandPrompt(testCase.input)
+		if testCase.expected[0] == '^' {
+			if !regexp.MustCompile(testCase.expected).MatchString(res) {
+				t.Errorf("testing %q, expected match: %q, got: %q", testCase.input, testCase.expected, res)
+			}
+		} else if res != testCase.expected {
+			t.Errorf("testing %q, expected: %q, got: %q", testCase.input, testCase.expected, res)
+		}
+	}
+
+	os.Chdir("/")
+	if res := expandPrompt(`\w \W`); res != "/ /" {
+		t.Errorf("expected root directory, got: %q", res)
+	}
+	vars.unset("PS2")
+	if res := prompt2(); res != "> " {
+		t.Errorf("expected default PS2, got: %q", res)
+	}
+}
+
+func TestGitBranch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myshell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "a", "b")
+	os.MkdirAll(sub, 0755)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+
+	testCases := []struct {
+		head     string
+		expected string
+	}{
+		{"ref: refs/heads/feature/x\n", "feature/x"},
+		{"0123456789abcdef0123456789abcdef01234567\n", "0123456"},
+	}
+	for _, testCase := range testCases {
+		ioutil.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte(testCase.head), 0644)
+		if res := gitBranch(sub); res != testCase.expected {
+			t.Errorf("testing %q, expected: %q, got: %q", testCase.head, testCase.expected, res)
+		}
+	}
+
+	// рабочее дерево: .git - файл со ссылкой на директорию репозитория
+	worktree := filepath.Join(dir, "wt")
+	os.MkdirAll(filepath.Join(dir, "gitdir"), 0755)
+	os.MkdirAll(worktree, 0755)
+	ioutil.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: ../gitdir\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "gitdir", "HEAD"), []byte("ref: refs/heads/wt\n"), 0644)
+	if res := gitBranch(worktree); res != "wt" {
+		t.Errorf("expected worktree branch, got: %q", res)
+	}
+
+	if res := gitBranch("/"); res != "" {
+		t.Errorf("expected no branch outside repository, got: %q", res)
+	}
+}
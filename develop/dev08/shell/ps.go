@@ -0,0 +1,480 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// clockTicks - число тактов в секунду, в которых /proc/[pid]/stat задает время (USER_HZ)
+const clockTicks = 100
+
+// procInfo - сведения о процессе из /proc
+type procInfo struct {
+	pid        int
+	ppid       int
+	uid        int // действующий пользователь
+	state      string
+	cpuTicks   uint64 // время процессора в режиме пользователя и ядра
+	startTicks uint64 // время запуска в тактах от загрузки системы
+	rss        int64  // резидентная память, КБ
+	comm       string // имя исполняемого файла
+	cmdline    string // командная строка; для потоков ядра - [comm]
+}
+
+// parseStat разбирает содержимое /proc/[pid]/stat. Имя процесса в скобках может
+// содержать пробелы и скобки, поэтому поля отсчитываются от последней )
+func parseStat(data string) (procInfo, error) {
+	open := strings.IndexByte(data, '(')
+	close := strings.LastIndexByte(data, ')')
+	if open < 0 || close < open {
+		return procInfo{}, errors.New("malformed stat")
+	}
+	p := procInfo{comm: data[open+1 : close]}
+	fields := strings.Fields(data[close+1:])
+	if len(fields) < 22 {
+		return procInfo{}, errors.New("malformed stat")
+	}
+	var err error
+	if p.pid, err = strconv.Atoi(strings.TrimSpace(data[:open])); err != nil {
+		return procInfo{}, err
+	}
+	// fields[0] - третье поле stat (state), поэтому поле N находится в fields[N-3]
+	p.state = fields[0]
+	p.ppid, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	p.cpuTicks = utime + stime
+	p.startTicks, _ = strconv.ParseUint(fields[19], 10, 64)
+	pages, _ := strconv.ParseInt(fields[21], 10, 64)
+	p.rss = pages * int64(os.Getpagesize()) / 1024
+	return p, nil
+}
+
+// readProc читает сведения о процессе pid
+func readProc(pid int) (procInfo, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return procInfo{}, err
+	}
+	p, err := parseStat(string(data))
+	if err != nil {
+		return procInfo{}, err
+	}
+
+	if status, err := ioutil.ReadFile(filepath.Join(dir, "status")); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			// Uid: реальный, действующий, сохраненный, файловой системы
+			if fields := strings.Fields(line); len(fields) > 2 && fields[0] == "Uid:" {
+				p.uid, _ = strconv.Atoi(fields[2])
+			}
+		}
+	}
+
+	cmdline, _ := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+	p.cmdline = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	if p.cmdline == "" {
+		p.cmdline = "[" + p.comm + "]"
+	}
+	return p, nil
+}
+
+// readProcs читает сведения о всех процессах, отсортированные по номеру.
+// Процессы, завершившиеся во время чтения, пропускаются
+func readProcs() ([]procInfo, error) {
+	infos, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var procs []procInfo
+	for _, info := range infos {
+		pid, err := strconv.Atoi(info.Name())
+		if err != nil {
+			continue
+		}
+		if p, err := readProc(pid); err == nil {
+			procs = append(procs, p)
+		}
+	}
+	sort.Slice(procs, func(i, k int) bool { return procs[i].pid < procs[k].pid })
+	return procs, nil
+}
+
+// systemTimes возвращает время работы системы в секундах и момент ее загрузки
+func systemTimes() (float64, time.Time) {
+	var uptime float64
+	if data, err := ioutil.ReadFile("/proc/uptime"); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) > 0 {
+			uptime, _ = strconv.ParseFloat(fields[0], 64)
+		}
+	}
+	boot := time.Now().Add(-time.Duration(uptime * float64(time.Second)))
+	return uptime, boot
+}
+
+// psColumn - столбец вывода ps
+type psColumn struct {
+	header string
+	right  bool // выравнивание по правому краю (числа)
+	value  func(p procInfo, ctx *psContext) string
+}
+
+// psContext - общие для всех строк вывода ps данные
+type psContext struct {
+	uptime float64
+	boot   time.Time
+	users  map[int]string
+	prefix map[int]string // отступы дерева процессов (--tree) для столбцов с командой
+}
+
+// userName возвращает имя пользователя uid или сам uid, если имя неизвестно
+func (c *psContext) userName(uid int) string {
+	if name, ok := c.users[uid]; ok {
+		return name
+	}
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	c.users[uid] = name
+	return name
+}
+
+// psColumns - столбцы, которые можно выбрать в ps -o, с синонимами в стиле procps
+var psColumns = map[string]psColumn{
+	"pid":  {"PID", true, func(p procInfo, _ *psContext) string { return strconv.Itoa(p.pid) }},
+	"ppid": {"PPID", true, func(p procInfo, _ *psContext) string { return strconv.Itoa(p.ppid) }},
+	"user": {"USER", false, func(p procInfo, c *psContext) string { return c.userName(p.uid) }},
+	"stat": {"STAT", false, func(p procInfo, _ *psContext) string { return p.state }},
+	"%cpu": {"%CPU", true, func(p procInfo, c *psContext) string {
+		elapsed := c.uptime - float64(p.startTicks)/clockTicks
+		if elapsed <= 0 {
+			return "0.0"
+		}
+		return strconv.FormatFloat(float64(p.cpuTicks)/clockTicks/elapsed*100, 'f', 1, 64)
+	}},
+	"rss": {"RSS", true, func(p procInfo, _ *psContext) string { return strconv.FormatInt(p.rss, 10) }},
+	"start": {"START", false, func(p procInfo, c *psContext) string {
+		start := c.boot.Add(time.Duration(p.startTicks) * time.Second / clockTicks)
+		if start.Format("2006-01-02") == time.Now().Format("2006-01-02") {
+			return start.Format("15:04")
+		}
+		return start.Format("Jan02")
+	}},
+	"cmd":  {"CMD", false, func(p procInfo, c *psContext) string { return c.prefix[p.pid] + p.cmdline }},
+	"comm": {"COMMAND", false, func(p procInfo, c *psContext) string { return c.prefix[p.pid] + p.comm }},
+}
+
+// psAliases - синонимы имен столбцов
+var psAliases = map[string]string{
+	"state": "stat", "s": "stat", "pcpu": "%cpu", "rssize": "rss", "stime": "start",
+	"args": "cmd", "command": "cmd", "uname": "user", "ucomm": "comm",
+}
+
+// psDefault - столбцы ps по умолчанию
+const psDefault = "pid,ppid,user,stat,%cpu,rss,start,cmd"
+
+// processPS эмулирует команду ps: выводит процессы из /proc. По умолчанию выводятся
+// шелл и его потомки; -A (-e) - все процессы, -u USER - процессы пользователя.
+// -o задает столбцы через запятую, --tree выводит процессы деревом
+func processPS(args []string, std stdio) error {
+	all, tree := false, false
+	userSpec := ""
+	columns := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-A" || arg == "-e":
+			all = true
+		case arg == "--tree" || arg == "--forest":
+			tree = true
+		case arg == "-u" || arg == "-o":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s: option requires an argument", arg)
+			}
+			i++
+			if arg == "-u" {
+				userSpec = args[i]
+			} else {
+				columns = append(columns, strings.Split(args[i], ",")...)
+			}
+		case strings.HasPrefix(arg, "-u"):
+			userSpec = arg[2:]
+		case strings.HasPrefix(arg, "-o"):
+			columns = append(columns, strings.Split(arg[2:], ",")...)
+		default:
+			return fmt.Errorf("%s: invalid option", arg)
+		}
+	}
+	if len(columns) == 0 {
+		columns = strings.Split(psDefault, ",")
+	}
+	var selected []psColumn
+	for _, name := range columns {
+		name = strings.ToLower(name)
+		if alias, ok := psAliases[name]; ok {
+			name = alias
+		}
+		column, ok := psColumns[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown output column", name)
+		}
+		selected = append(selected, column)
+	}
+
+	procs, err := readProcs()
+	if err != nil {
+		return err
+	}
+	switch {
+	case userSpec != "":
+		uid, err := lookupUID(userSpec)
+		if err != nil {
+			return err
+		}
+		procs = filterProcs(procs, func(p procInfo) bool { return p.uid == uid })
+	case !all:
+		procs = descendants(procs, os.Getpid())
+	}
+
+	ctx := &psContext{users: map[int]string{}, prefix: map[int]string{}}
+	ctx.uptime, ctx.boot = systemTimes()
+	if tree {
+		procs = treeOrder(procs, ctx.prefix)
+	}
+
+	rows := [][]string{{}}
+	for _, column := range selected {
+		rows[0] = append(rows[0], column.header)
+	}
+	for _, p := range procs {
+		row := []string{}
+		for _, column := range selected {
+			row = append(row, column.value(p, ctx))
+		}
+		rows = append(rows, row)
+	}
+	return writeColumns(std, rows, selected)
+}
+
+// writeColumns выводит таблицу, выравнивая столбцы; последний столбец не дополняется пробелами
+func writeColumns(std stdio, rows [][]string, columns []psColumn) error {
+	widths := make([]int, len(columns))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	builder := strings.Builder{}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i > 0 {
+				builder.WriteByte(' ')
+			}
+			switch {
+			case columns[i].right:
+				builder.WriteString(strings.Repeat(" ", widths[i]-len(cell)) + cell)
+			case i == len(row)-1:
+				builder.WriteString(cell)
+			default:
+				builder.WriteString(cell + strings.Repeat(" ", widths[i]-len(cell)))
+			}
+		}
+		builder.WriteByte('\n')
+	}
+	_, err := fmt.Fprint(std.out, builder.String())
+	return err
+}
+
+// lookupUID возвращает uid пользователя по имени или номеру
+func lookupUID(spec string) (int, error) {
+	if uid, err := strconv.Atoi(spec); err == nil {
+		return uid, nil
+	}
+	u, err := user.Lookup(spec)
+	if err != nil {
+		return 0, fmt.Errorf("%s: no such user", spec)
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// filterProcs возвращает процессы, для которых keep возвращает true
+func filterProcs(procs []procInfo, keep func(p procInfo) bool) []procInfo {
+	var res []procInfo
+	for _, p := range procs {
+		if keep(p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// descendants возвращает процесс root и всех его потомков
+func descendants(procs []procInfo, root int) []procInfo {
+	keep := map[int]bool{root: true}
+	// процессы отсортированы по номеру, но потомок может получить меньший номер,
+	// поэтому проход повторяется, пока набор не перестанет расти
+	for changed := true; changed; {
+		changed = false
+		for _, p := range procs {
+			if !keep[p.pid] && keep[p.ppid] {
+				keep[p.pid] = true
+				changed = true
+			}
+		}
+	}
+	return filterProcs(procs, func(p procInfo) bool { return keep[p.pid] })
+}
+
+// treeOrder упорядочивает процессы деревом: потомки следуют за родителем.
+// В prefix записываются отступы для столбцов с командой, как в ps --forest
+func treeOrder(procs []procInfo, prefix map[int]string) []procInfo {
+	present := map[int]bool{}
+	for _, p := range procs {
+		present[p.pid] = true
+	}
+	children := map[int][]procInfo{}
+	var roots []procInfo
+	for _, p := range procs {
+		if present[p.ppid] && p.ppid != p.pid {
+			children[p.ppid] = append(children[p.ppid], p)
+		} else {
+			roots = append(roots, p)
+		}
+	}
+
+	var res []procInfo
+	var visit func(p procInfo, depth int)
+	visit = func(p procInfo, depth int) {
+		if depth > 0 {
+			prefix[p.pid] = strings.Repeat("    ", depth-1) + ` \_ `
+		}
+		res = append(res, p)
+		for _, child := range children[p.pid] {
+			visit(child, depth+1)
+		}
+	}
+	for _, p := range roots {
+		visit(p, 0)
+	}
+	return res
+}
+
+// kill эмулирует одноименную команду: посылает сигнал (по умолчанию SIGTERM) процессам
+// и заданиям (%N). Сигнал задается как -SIGNAL, -s SIGNAL или -n N; -l выводит имена сигналов
+func kill(args []string, std stdio) error {
+	sig := syscall.SIGTERM
+	if len(args) > 0 && args[0] == "-l" {
+		return listSignals(args[1:], std)
+	}
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "--" {
+		spec := args[0][1:]
+		args = args[1:]
+		if spec == "s" || spec == "n" {
+			if len(args) == 0 {
+				return fmt.Errorf("-%s: option requires an argument", spec)
+			}
+			spec, args = args[0], args[1:]
+		}
+		var ok bool
+		if sig, ok = parseSignal(spec); !ok {
+			return fmt.Errorf("%s: invalid signal specification", spec)
+		}
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return errors.New("usage: kill [-s sigspec | -n signum | -sigspec] pid | jobspec ... or kill -l [sigspec]")
+	}
+
+	var res error
+	for _, arg := range args {
+		if err := killTarget(arg, sig); err != nil {
+			reportError(std.err, "kill", err)
+			res = statusError(1)
+		}
+	}
+	return res
+}
+
+// killTarget посылает сигнал процессу или группе процессов задания (%N).
+// Остановленное задание после SIGTERM и SIGHUP продолжается, чтобы получить сигнал, как в bash
+func killTarget(arg string, sig syscall.Signal) error {
+	if strings.HasPrefix(arg, "%") {
+		j, err := jobs.find(arg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+		if j.pgid == 0 {
+			return nil // задание без внешних процессов
+		}
+		stopped := j.isStopped()
+		if err := jobs.signalLocked(j, sig); err != nil {
+			return fmt.Errorf("(%d) - %s", j.pgid, errorMessage(err))
+		}
+		if stopped && (sig == syscall.SIGTERM || sig == syscall.SIGHUP) {
+			jobs.signalLocked(j, syscall.SIGCONT)
+		}
+		return nil
+	}
+
+	pid, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("%s: arguments must be process or job IDs", arg)
+	}
+	// сигнал самому шеллу (или его группе) обрабатывается до следующей команды
+	n, caught := shellSignals.count(sig)
+	self := caught && (pid == os.Getpid() || pid == 0 || pid == -syscall.Getpgrp())
+	if err := syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("(%d) - %s", pid, errorMessage(err))
+	}
+	if self {
+		shellSignals.wait(sig, n)
+	}
+	return nil
+}
+
+// listSignals выводит имена сигналов; с аргументами - имя сигнала по номеру
+// (или по коду возврата 128+N) и номер по имени
+func listSignals(args []string, std stdio) error {
+	if len(args) == 0 {
+		names := make([]string, len(signalNames))
+		for i, s := range signalNames {
+			names[i] = s.name
+		}
+		_, err := fmt.Fprintln(std.out, strings.Join(names, " "))
+		return err
+	}
+	var res error
+	for _, arg := range args {
+		if n, err := strconv.Atoi(arg); err == nil {
+			if n > 128 {
+				n -= 128
+			}
+			if name := signalString(syscall.Signal(n)); name != "" {
+				fmt.Fprintln(std.out, name)
+				continue
+			}
+		} else if sig, ok := parseSignal(arg); ok {
+			fmt.Fprintln(std.out, int(sig))
+			continue
+		}
+		reportError(std.err, "kill", fmt.Errorf("%s: invalid signal specification", arg))
+		res = statusError(1)
+	}
+	return res
+}
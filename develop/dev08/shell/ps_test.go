@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseStat(t *testing.T) {
+	stat := "42 (my (odd) prog) S 1 42 42 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 1 0 12345 1000 3 18446744073709551615"
+	p, err := parseStat(stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rss := 3 * int64(os.Getpagesize()) / 1024
+	if p.pid != 42 || p.ppid != 1 || p.comm != "my (odd) prog" || p.state != "S" ||
+		p.cpuTicks != 200 || p.startTicks != 12345 || p.rss != rss {
+		t.Errorf("unexpected result: %+v", p)
+	}
+	if _, err := parseStat("42 prog S 1"); err == nil {
+		t.Errorf("expected error for malformed stat")
+	}
+}
+
+func TestPS(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	testCases := []struct {
+		input  string
+		status int
+		match  string
+	}{
+		{"ps", 0, `^ *PID +PPID USER +STAT %CPU +RSS START +CMD\n *` + pid + ` .*\n$`},
+		{"ps -o pid,comm", 0, `^ *PID COMMAND\n *` + pid + ` \S+\n$`},
+		{"ps -o pid -o args -A | head -2", 0, `^ *PID CMD\n +1 \S`},
+		{"ps -u 0 -o user,pid | head -1", 0, `^USER +PID\n$`},
+		{"sleep 0.5 & ps --tree -o pid,cmd; wait", 0, `\n *\d+  \\_ sleep 0.5\n`},
+		{"ps -o foo", 1, `^myshell: ps: foo: unknown output column\n$`},
+		{"ps -x", 1, `^myshell: ps: -x: invalid option\n$`},
+		{"ps -u no-such-user-x", 1, `^myshell: ps: no-such-user-x: no such user\n$`},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || !regexp.MustCompile(testCase.match).MatchString(output) {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.match, status, output)
+		}
+	}
+}
+
+func TestKill(t *testing.T) {
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"kill -l | cut -d' ' -f1-3", 0, "HUP INT QUIT\n"},
+		{"kill -l 9 130 KILL", 0, "KILL\nINT\n9\n"},
+		{"sleep 5 & kill %1; wait %1", 143, ""},
+		{"sleep 5 & kill -KILL $!; wait $!", 137, ""},
+		{"sleep 5 & kill -s int %%; wait %%", 130, ""},
+		{"sleep 5 & kill -n 15 $!; wait $!", 143, ""},
+		{"sleep 5 & kill -SIGUSR1 %1; wait %1", 138, ""},
+		{"kill -FOO 1", 1, "myshell: kill: FOO: invalid signal specification\n"},
+		{"kill -s", 1, "myshell: kill: -s: option requires an argument\n"},
+		{"kill %9", 1, "myshell: kill: %9: no such job\n"},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || output != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, output)
+		}
+	}
+	if _, output := run(t, "kill -l"); len(strings.Fields(output)) != len(signalNames) {
+		t.Errorf("expected all signal names, got: %q", output)
+	}
+}
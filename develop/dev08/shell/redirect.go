@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// stdio - стандартные потоки команды. Встроенные команды и внешние программы
+// получают потоки в одном и том же виде, поэтому перенаправляются одинаково.
+type stdio struct {
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+}
+
+// closers - файлы, открытые при перенаправлении, которые нужно закрыть после запуска команды
+type closers []io.Closer
+
+// Close закрывает все файлы
+func (c closers) Close() {
+	for _, closer := range c {
+		closer.Close()
+	}
+}
+
+// errBadFd - ошибка перенаправления дескриптора, отличного от 0, 1 и 2
+var errBadFd = errors.New("bad file descriptor")
+
+// applyRedirects применяет перенаправления redirects к потокам std в порядке записи
+// (поэтому ">file 2>&1" и "2>&1 >file" дают разный результат, как в bash).
+// Имена файлов раскрываются и отсчитываются в состоянии задания j.
+// Возвращает новые потоки и открытые файлы, которые вызывающий должен закрыть.
+func applyRedirects(redirects []redirect, std stdio, j *job) (stdio, closers, error) {
+	var files closers
+	for _, r := range redirects {
+		var err error
+		std, files, err = applyRedirect(r, std, files, j)
+		if err != nil {
+			files.Close()
+			return std, nil, err
+		}
+	}
+	return std, files, nil
+}
+
+// applyRedirect применяет одно перенаправление
+func applyRedirect(r redirect, std stdio, files closers, j *job) (stdio, closers, error) {
+	var e = newExpander(std, j)
+	var target = e.single(r.target)
+	var fd = r.fd
+	if fd < 0 {
+		fd = 1
+		if strings.HasPrefix(r.op, "<") {
+			fd = 0
+		}
+	}
+
+	// >&file без номера дескриптора означает то же, что &>file
+	if r.op == ">&" && r.fd < 0 {
+		if _, err := strconv.Atoi(target); err != nil && target != "-" {
+			r.op = "&>"
+		}
+	}
+
+	switch r.op {
+	case "<":
+		var f, err = os.Open(j.scope().path(target))
+		if err != nil {
+			return std, files, err
+		}
+		std, err = setReader(std, fd, f)
+		return std, append(files, f), err
+	case "<<":
+		var body = r.heredoc.body
+		if !r.heredoc.quoted {
+			body = e.text(body)
+		}
+		var std, err = setReader(std, fd, strings.NewReader(body))
+		return std, files, err
+	case ">", ">>", "&>", "&>>":
+		var flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if strings.HasSuffix(r.op, ">>") {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		var f, err = os.OpenFile(j.scope().path(target), flags, 0666)
+		if err != nil {
+			return std, files, err
+		}
+		files = append(files, f)
+		if strings.HasPrefix(r.op, "&") {
+			std.out, std.err = f, f
+			return std, files, nil
+		}
+		std, err = setWriter(std, fd, f)
+		return std, files, err
+	case ">&", "<&":
+		if target == "-" { // закрытие дескриптора
+			if fd == 0 {
+				var std, err = setReader(std, fd, strings.NewReader(""))
+				return std, files, err
+			}
+			var std, err = setWriter(std, fd, io.Discard)
+			return std, files, err
+		}
+		var source, err = strconv.Atoi(target)
+		if err != nil {
+			return std, files, fmt.Errorf("%s: ambiguous redirect", target)
+		}
+		if fd == 0 || source == 0 {
+			if fd != 0 || source != 0 {
+				return std, files, errBadFd
+			}
+			return std, files, nil
+		}
+		var w io.Writer
+		switch source {
+		case 1:
+			w = std.out
+		case 2:
+			w = std.err
+		default:
+			return std, files, errBadFd
+		}
+		std, err = setWriter(std, fd, w)
+		return std, files, err
+	}
+	return std, files, fmt.Errorf("unknown redirection %s", r.op)
+}
+
+// setReader заменяет поток ввода с номером fd
+func setReader(std stdio, fd int, r io.Reader) (stdio, error) {
+	if fd != 0 {
+		return std, errBadFd
+	}
+	std.in = r
+	return std, nil
+}
+
+// setWriter заменяет поток вывода с номером fd
+func setWriter(std stdio, fd int, w io.Writer) (stdio, error) {
+	switch fd {
+	case 1:
+		std.out = w
+	case 2:
+		std.err = w
+	default:
+		return std, errBadFd
+	}
+	return std, nil
+}
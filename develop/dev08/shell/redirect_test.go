@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyRedirects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myshell")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "out")
+
+	testCases := []struct {
+		name  string
+		input string
+		toOut bool // ожидается, что stdout указывает на исходный stdout
+		toErr bool // ожидается, что stderr указывает на исходный stdout
+	}{
+		{"stderr to stdout", "x 2>&1", true, true},
+		{"stdout to file then stderr", "x >" + file + " 2>&1", false, false},
+		{"stderr then stdout to file", "x 2>&1 >" + file, false, true},
+	}
+
+	for _, testCase := range testCases {
+		l, err := parse(testCase.input)
+		if err != nil {
+			t.Fatalf("failed test %q, expected no parse error, got: %s", testCase.name, err)
+		}
+		c := l.items[0].andOr.pipelines[0].commands[0].(*simpleCommand)
+
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		std, files, err := applyRedirects(c.redirects, stdio{out: out, err: errOut}, nil)
+		if err != nil {
+			t.Errorf("failed test %q, expected no error, got: %s", testCase.name, err)
+			continue
+		}
+		files.Close()
+
+		if (std.out == out) != testCase.toOut {
+			t.Errorf("failed test %q, stdout points to original stdout: %v", testCase.name, std.out == out)
+		}
+		if (std.err == out) != testCase.toErr {
+			t.Errorf("failed test %q, stderr points to original stdout: %v", testCase.name, std.err == out)
+		}
+	}
+
+	l, _ := parse("x 3>" + file)
+	c := l.items[0].andOr.pipelines[0].commands[0].(*simpleCommand)
+	if _, _, err := applyRedirects(c.redirects, stdio{}, nil); err != errBadFd {
+		t.Errorf("expected bad file descriptor error, got: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Состояние шелла: переменные, функции, псевдонимы, обработчики сигналов, стек директорий
+// и рабочая директория. Подоболочка (команды в скобках, подстановка команды) получает
+// копию состояния, поэтому ее присваивания, определения функций, cd и trap не действуют
+// на шелл. Рабочая директория копии хранится в самой копии, а не меняется через os.Chdir:
+// директория процесса одна на все горутины, и ее смена подоболочкой повлияла бы
+// на одновременно выполняющиеся команды шелла.
+
+// scope - состояние шелла или подоболочки
+type scope struct {
+	vars      *variables
+	functions *functionTable
+	aliases   *aliasTable
+	traps     *trapTable
+	dirs      *directoryStack
+	wd        string // рабочая директория подоболочки; "" - рабочая директория процесса шелла
+}
+
+// shell - состояние самого шелла
+var shell = &scope{vars: vars, functions: functions, aliases: aliases, traps: traps, dirs: dirStack}
+
+// scope возвращает состояние, в котором выполняются команды задания (nil - команды шелла)
+func (j *job) scope() *scope {
+	if j == nil || j.sc == nil {
+		return shell
+	}
+	return j.sc
+}
+
+// subshell создает состояние выполнения подоболочки: как этап конвейера, но с копией
+// состояния шелла, изменения которой не видны снаружи
+func (j *job) subshell() *job {
+	sub := j.stage()
+	sub.sc = j.scope().clone()
+	return sub
+}
+
+// clone возвращает копию состояния для подоболочки
+func (s *scope) clone() *scope {
+	wd, err := s.getwd()
+	if err != nil {
+		wd, _ = s.vars.get("PWD")
+	}
+	return &scope{
+		vars:      s.vars.clone(),
+		functions: s.functions.clone(),
+		aliases:   s.aliases.clone(),
+		traps:     s.traps.clone(),
+		dirs:      s.dirs.clone(),
+		wd:        wd,
+	}
+}
+
+// path возвращает путь к файлу name относительно рабочей директории
+func (s *scope) path(name string) string {
+	if s.wd == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.wd, name)
+}
+
+// getwd возвращает рабочую директорию
+func (s *scope) getwd() (string, error) {
+	if s.wd != "" {
+		return s.wd, nil
+	}
+	return os.Getwd()
+}
+
+// chdir меняет рабочую директорию: у шелла - директорию процесса, у подоболочки - свою
+func (s *scope) chdir(dir string) error {
+	if s.wd == "" {
+		return os.Chdir(dir)
+	}
+	path := s.path(dir)
+	info, err := os.Stat(path)
+	if err == nil && !info.IsDir() {
+		err = syscall.ENOTDIR
+	}
+	if err == nil {
+		// как и chdir, переход требует права на поиск в директории
+		err = syscall.Access(path, 1)
+	}
+	if err != nil {
+		return &os.PathError{Op: "chdir", Path: dir, Err: unwrapPathError(err)}
+	}
+	s.wd = path
+	return nil
+}
+
+// unwrapPathError возвращает системную ошибку из *os.PathError
+func unwrapPathError(err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// parse разбирает текст команд с псевдонимами этого состояния
+func (s *scope) parse(input string) (*list, error) {
+	return parseAliases(input, s.aliases)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// Составные команды и скрипты. Составные команды выполняются в задании охватывающего
+// конвейера. break, continue, return и exit не возвращаются по цепочке вызовов,
+// а запоминаются в задании (job.flow): списки команд прекращают выполнение,
+// пока действие не обработает цикл, вызов функции или подоболочка.
+// exit на верхнем уровне запоминается в vars, и шелл завершается после текущей строки.
+
+// flowKind - действие, прерывающее последовательное выполнение команд
+type flowKind int
+
+const (
+	flowNone     flowKind = iota
+	flowBreak             // break - выход из цикла
+	flowContinue          // continue - следующая итерация цикла
+	flowReturn            // return - выход из функции
+	flowExit              // exit - завершение шелла или подоболочки
+)
+
+// flow - ожидающее действие break, continue, return или exit
+type flow struct {
+	kind   flowKind
+	count  int // для break и continue - сколько охватывающих циклов прервать
+	status int // для return и exit - код возврата
+}
+
+// interrupted сообщает, что выполнение списка команд задания j нужно прекратить:
+// вызван exit, ожидает обработки break, continue или return, либо нажат Ctrl-C
+// (фоновые задания Ctrl-C не прерывает)
+func interrupted(j *job) bool {
+	if vars.isExiting() {
+		return true
+	}
+	if j == nil {
+		return traps.isInterrupted()
+	}
+	return j.flow.kind != flowNone || !j.owned && traps.isInterrupted()
+}
+
+// functionTable - функции, определенные в шелле
+type functionTable struct {
+	mu     sync.Mutex
+	bodies map[string]*functionDef
+}
+
+// functions - функции шелла
+var functions = &functionTable{bodies: map[string]*functionDef{}}
+
+// clone возвращает копию функций для подоболочки
+func (t *functionTable) clone() *functionTable {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := &functionTable{bodies: map[string]*functionDef{}}
+	for name, f := range t.bodies {
+		c.bodies[name] = f
+	}
+	return c
+}
+
+// get возвращает функцию с именем name или nil
+func (t *functionTable) get(name string) *functionDef {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.bodies[name]
+}
+
+// set определяет (или переопределяет) функцию
+func (t *functionTable) set(f *functionDef) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bodies[f.name] = f
+}
+
+// unset удаляет функцию
+func (t *functionTable) unset(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.bodies, name)
+}
+
+// execIf выполняет if: первую ветвь, условие которой завершилось успешно, иначе ветвь else
+func execIf(c *ifClause, std stdio, j *job) int {
+	for i, cond := range c.conds {
+		status := execList(cond, std, j)
+		if interrupted(j) {
+			return status
+		}
+		if status == 0 {
+			return execList(c.bodies[i], std, j)
+		}
+	}
+	if c.elseBody != nil {
+		return execList(c.elseBody, std, j)
+	}
+	return 0
+}
+
+// loopDone обрабатывает break и continue после очередной части цикла и сообщает,
+// что цикл нужно завершить
+func loopDone(j *job) bool {
+	switch j.flow.kind {
+	case flowNone:
+		return interrupted(j)
+	case flowBreak, flowContinue:
+		j.flow.count--
+		if j.flow.count > 0 { // break N и continue N действуют и на внешние циклы
+			return true
+		}
+		kind := j.flow.kind
+		j.flow = flow{}
+		return kind == flowBreak
+	}
+	return true
+}
+
+// execWhile выполняет while: тело, пока условие завершается успешно
+func execWhile(w *whileLoop, std stdio, j *job) int {
+	j.loops++
+	defer func() { j.loops-- }()
+	status := 0
+	for {
+		cond := execList(w.cond, std, j)
+		if loopDone(j) || cond != 0 {
+			return status
+		}
+		status = execList(w.body, std, j)
+		if loopDone(j) {
+			return status
+		}
+	}
+}
+
+// execFor выполняет for: тело для каждого слова списка (без in - для каждого позиционного параметра)
+func execFor(f *forLoop, std stdio, j *job) int {
+	v := j.scope().vars
+	items := v.positional()
+	if f.items != nil {
+		items = newExpander(std, j).words(f.items)
+	}
+	j.loops++
+	defer func() { j.loops-- }()
+	status := 0
+	for _, item := range items {
+		v.set(f.name, item)
+		status = execList(f.body, std, j)
+		if loopDone(j) {
+			break
+		}
+	}
+	return status
+}
+
+// execCase выполняет ветвь case, один из шаблонов которой соответствует слову
+func execCase(c *caseClause, std stdio, j *job) int {
+	e := newExpander(std, j)
+	subject := e.single(c.subject)
+	for _, item := range c.items {
+		for _, pattern := range item.patterns {
+			if matchPattern(e.pattern(pattern), subject) {
+				return execList(item.body, std, j)
+			}
+		}
+	}
+	return 0
+}
+
+// callFunction выполняет функцию с аргументами args[1:] в качестве позиционных параметров
+func callFunction(f *functionDef, args []string, std stdio, j *job) int {
+	v := j.scope().vars
+	old := v.setPositional(args[1:])
+	defer v.setPositional(old)
+	j.functions++
+	defer func() { j.functions-- }()
+
+	status := runCommand(processCommand(f.body, std, j), std)
+	if j.flow.kind == flowReturn {
+		status = j.flow.status
+		j.flow = flow{}
+	}
+	return status
+}
+
+// statusArg разбирает необязательный числовой аргумент break, continue, return и exit
+func statusArg(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	if len(args) > 1 {
+		return 0, fmt.Errorf("too many arguments")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("%s: numeric argument required", args[0])
+	}
+	return n, nil
+}
+
+// processLoopControl эмулирует команды break и continue: прерывает n охватывающих циклов
+// или переходит к следующей итерации n-го из них
+func processLoopControl(name string, args []string, std stdio, j *job) error {
+	n, err := statusArg(args, 1)
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return fmt.Errorf("%d: loop count out of range", n)
+	}
+	if j.loops == 0 {
+		reportError(std.err, name, fmt.Errorf("only meaningful in a `for' or `while' loop"))
+		return nil
+	}
+	if n > j.loops {
+		n = j.loops
+	}
+	kind := flowBreak
+	if name == "continue" {
+		kind = flowContinue
+	}
+	j.flow = flow{kind: kind, count: n}
+	return nil
+}
+
+// processReturn эмулирует команду return: завершает функцию с кодом возврата N (по умолчанию $?)
+func processReturn(args []string, j *job) error {
+	status, err := statusArg(args, j.scope().vars.lastStatus())
+	if err != nil {
+		return err
+	}
+	if j.functions == 0 {
+		return fmt.Errorf("can only `return' from a function")
+	}
+	j.flow = flow{kind: flowReturn, status: status & 0xff}
+	return statusResult(status & 0xff)()
+}
+
+// processExit эмулирует команду exit: завершает шелл (или подоболочку) с кодом возврата N
+// (по умолчанию $?)
+func processExit(args []string, j *job) error {
+	status, err := statusArg(args, j.scope().vars.lastStatus())
+	if err != nil {
+		status = 2
+	}
+	j.flow = flow{kind: flowExit, status: status & 0xff}
+	if err != nil {
+		return err
+	}
+	return statusResult(status & 0xff)()
+}
+
+// runArgs выполняет команды, заданные аргументами шелла: "-c команды [$0 [параметры...]]"
+// или "скрипт [параметры...]". Возвращает код возврата шелла
+func runArgs(args []string) int {
+	var src string
+	source, name, params := args[0], "myshell", args[1:]
+	if args[0] == "-c" {
+		if len(args) < 2 {
+			reportError(os.Stderr, "-c", fmt.Errorf("option requires an argument"))
+			return 2
+		}
+		src, params = args[1], args[2:]
+		if len(params) > 0 {
+			name, params = params[0], params[1:]
+		}
+	} else {
+		data, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			reportError(os.Stderr, "", err)
+			return 127
+		}
+		src, name = string(data), args[0]
+	}
+	vars.setName(name)
+	vars.setPositional(params)
+	return runScript(src, source, stdio{in: os.Stdin, out: os.Stdout, err: os.Stderr})
+}
+
+// runScript выполняет текст скрипта целиком (строка #! в начале - комментарий)
+// и возвращает код возврата последней команды или exit. source - имя скрипта (или -c) для сообщений об ошибках
+func runScript(src, source string, std stdio) int {
+	l, err := parse(src)
+	if err != nil {
+		reportError(std.err, source, err)
+		return 2
+	}
+	status := execList(l, std, nil)
+	traps.runPending(std)
+	interrupted := traps.takeInterrupted()
+	if code, ok := vars.takeExit(); ok {
+		status = code
+	} else if interrupted {
+		status = 128 + int(syscall.SIGINT)
+	}
+	return traps.runExit(std, status)
+}
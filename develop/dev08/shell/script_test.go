@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseCompound(t *testing.T) {
+	testCases := []struct {
+		input      string
+		incomplete bool
+		ok         bool
+	}{
+		{"if true; then echo a; elif false; then echo b; else echo c; fi", false, true},
+		{"while false; do echo; done > /dev/null", false, true},
+		{"for x in a b; do echo $x; done", false, true},
+		{"for x\ndo echo $x\ndone", false, true},
+		{"case $x in a|b) echo ab;; (c) echo c;; *) ;; esac", false, true},
+		{"case x in\n  x) echo x\nesac", false, true},
+		{"f() { echo f; }", false, true},
+		{"function g { echo g; }", false, true},
+		{"{ echo a; echo b; } | cat", false, true},
+		{"echo fi done }", false, true},
+		{"if true; then", true, false},
+		{"while true; do echo", true, false},
+		{"f() {", true, false},
+		{"if true; then fi", false, false},
+		{"fi", false, false},
+		{"for 1x in a; do :; done", false, false},
+		{"f() echo", false, false},
+	}
+
+	for _, testCase := range testCases {
+		_, err := parse(testCase.input)
+		if (err == nil) != testCase.ok || isIncomplete(err) != testCase.incomplete {
+			t.Errorf("testing %q, expected ok=%t incomplete=%t, got: %v", testCase.input, testCase.ok, testCase.incomplete, err)
+		}
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"if false; then echo a; elif true; then echo b; else echo c; fi", 0, "b\n"},
+		{"if false; then echo a; fi", 0, ""},
+		{"if false; then :; else sh -c 'exit 4'; fi", 4, ""},
+		{"for x in a 'b c'; do echo $x; done", 0, "a\nb c\n"},
+		{"for x in a b c; do if [ $x = b ]; then continue; fi; echo $x; done", 0, "a\nc\n"},
+		{"for x in 1 2; do for y in a b; do [ $y = b ] && continue 2; echo $x$y; done; done", 0, "1a\n2a\n"},
+		{"for x in 1 2; do for y in a b; do break 2; done; done; echo $x$y", 0, "1a\n"},
+		{"W_I=; while [ \"$W_I\" != xxx ]; do W_I=x$W_I; done; echo $W_I", 0, "xxx\n"},
+		{"while true; do echo once; break; done", 0, "once\n"},
+		{"for x in a b; do echo $x; done | tr a-z A-Z", 0, "A\nB\n"},
+		{"{ echo a; echo b; } | sort -r", 0, "b\na\n"},
+		{"case abc in a*) echo a;; *) echo other;; esac", 0, "a\n"},
+		{"case a/b in *) echo any;; esac", 0, "any\n"},
+		{"case x in y|x) echo yx;; esac", 0, "yx\n"},
+		{"case '*' in \\*) echo star;; esac", 0, "star\n"},
+		{"case a in '*') echo star;; *) echo not;; esac", 0, "not\n"},
+		{"case b in [a-c]) echo range;; esac", 0, "range\n"},
+		{"case d in [!a-c]) echo negated;; esac", 0, "negated\n"},
+		{"f() { echo \"$# $1 $2\"; }; f 'a b' c", 0, "2 a b c\n"},
+		{"f() { for a in \"$@\"; do echo [$a]; done; }; f 'a b' c", 0, "[a b]\n[c]\n"},
+		{"f() { echo [\"$@\"]; }; f", 0, "[]\n"},
+		{"f() { return 3; echo no; }; f; echo $?", 0, "3\n"},
+		{"f() { for x in a b; do return 5; done; }; f", 5, ""},
+		{"f() { echo $1; }; f a; echo [$1]", 0, "a\n[]\n"},
+		{"f() { echo f; }; unset -f f; f", 127, "myshell: f: command not found\n"},
+		{"(exit 4); echo $?", 0, "4\n"},
+		{"(SUB_X=1; sub_f() { :; }); echo [$SUB_X]; type sub_f", 1, "[]\nmyshell: type: sub_f: not found\n"},
+		{"(alias sub_a=echo; set -o pipefail; trap 'echo t' INT); alias sub_a; set -o | grep -c 'pipefail.*off'; trap", 0, "myshell: alias: sub_a: not found\n1\n"},
+		{"(trap 'echo exit' EXIT; echo body); echo after", 0, "body\nexit\nafter\n"},
+		{"break", 0, "myshell: break: only meaningful in a `for' or `while' loop\n"},
+		{"return", 1, "myshell: return: can only `return' from a function\n"},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || output != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, output)
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	defer vars.setPositional(vars.setPositional([]string{"one", "two words"}))
+
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"#!/bin/myshell\necho $1; echo $2\n", 0, "one\ntwo words\n"},
+		{"for a; do echo $a; done", 0, "one\ntwo words\n"},
+		{"echo a; exit 3; echo b", 3, "a\n"},
+		{"f() { exit 4; }; f; echo no", 4, ""},
+		{"while true; do exit; done", 0, ""},
+		{"exit abc", 2, "myshell: exit: abc: numeric argument required\n"},
+		{"if true; then", 2, "myshell: -c: syntax error: unexpected end of input\n"},
+	}
+
+	for _, testCase := range testCases {
+		out := &bytes.Buffer{}
+		status := runScript(testCase.input, "-c", stdio{in: strings.NewReader(""), out: out, err: out})
+		if status != testCase.status || out.String() != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, out.String())
+		}
+	}
+}
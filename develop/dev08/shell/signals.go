@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Сигналы шелла. SIGINT, SIGQUIT и SIGTERM перехватываются (а не игнорируются, чтобы
+// запущенные программы получали их с действием по умолчанию) и пересылаются группе
+// процессов задания переднего плана. Сам шелл от них не завершается: Ctrl-C прерывает
+// выполнение текущей строки, и шелл возвращается к приглашению. Скрипт (неинтерактивный
+// шелл) по Ctrl-C и SIGTERM завершается. Обработчики trap выполняются между командами.
+
+// trapTable - обработчики сигналов, заданные командой trap, и сигналы, ожидающие обработки
+type trapTable struct {
+	mu          sync.Mutex
+	actions     map[string]string // EXIT, INT, TERM -> команды; "" - сигнал игнорируется
+	pending     []string          // сигналы, обработчики которых еще не выполнены
+	interrupted bool              // Ctrl-C прервал выполнение: оставшиеся команды строки не выполняются
+}
+
+// traps - обработчики сигналов шелла. Сигналы получает процесс шелла, поэтому ожидающие
+// сигналы и прерывание по Ctrl-C хранятся только здесь, а не в копиях подоболочек
+var traps = &trapTable{actions: map[string]string{}}
+
+// clone возвращает обработчики для подоболочки: как в bash, в ней сохраняется только
+// игнорирование сигналов, а обработчики шелла сбрасываются
+func (t *trapTable) clone() *trapTable {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := &trapTable{actions: map[string]string{}}
+	for name, action := range t.actions {
+		if action == "" {
+			c.actions[name] = action
+		}
+	}
+	return c
+}
+
+// trapSignals - сигналы, для которых можно задать обработчик, и их номера (EXIT - 0)
+var trapSignals = map[string]int{"EXIT": 0, "INT": int(syscall.SIGINT), "TERM": int(syscall.SIGTERM)}
+
+// signalNames - сигналы, которые можно послать командой kill, в порядке номеров
+var signalNames = []struct {
+	name string
+	sig  syscall.Signal
+}{
+	{"HUP", syscall.SIGHUP}, {"INT", syscall.SIGINT}, {"QUIT", syscall.SIGQUIT}, {"ILL", syscall.SIGILL},
+	{"TRAP", syscall.SIGTRAP}, {"ABRT", syscall.SIGABRT}, {"BUS", syscall.SIGBUS}, {"FPE", syscall.SIGFPE},
+	{"KILL", syscall.SIGKILL}, {"USR1", syscall.SIGUSR1}, {"SEGV", syscall.SIGSEGV}, {"USR2", syscall.SIGUSR2},
+	{"PIPE", syscall.SIGPIPE}, {"ALRM", syscall.SIGALRM}, {"TERM", syscall.SIGTERM}, {"STKFLT", syscall.SIGSTKFLT},
+	{"CHLD", syscall.SIGCHLD}, {"CONT", syscall.SIGCONT}, {"STOP", syscall.SIGSTOP}, {"TSTP", syscall.SIGTSTP},
+	{"TTIN", syscall.SIGTTIN}, {"TTOU", syscall.SIGTTOU}, {"URG", syscall.SIGURG}, {"XCPU", syscall.SIGXCPU},
+	{"XFSZ", syscall.SIGXFSZ}, {"VTALRM", syscall.SIGVTALRM}, {"PROF", syscall.SIGPROF}, {"WINCH", syscall.SIGWINCH},
+	{"IO", syscall.SIGIO}, {"PWR", syscall.SIGPWR}, {"SYS", syscall.SIGSYS},
+}
+
+// parseSignal разбирает обозначение сигнала для kill: имя (TERM, SIGTERM, term) или номер
+func parseSignal(spec string) (syscall.Signal, bool) {
+	if n, err := strconv.Atoi(spec); err == nil {
+		return syscall.Signal(n), n >= 0 && n < 65
+	}
+	name := strings.TrimPrefix(strings.ToUpper(spec), "SIG")
+	for _, s := range signalNames {
+		if s.name == name {
+			return s.sig, true
+		}
+	}
+	return 0, false
+}
+
+// signalString возвращает имя сигнала без префикса SIG или "", если сигнал неизвестен
+func signalString(sig syscall.Signal) string {
+	for _, s := range signalNames {
+		if s.sig == sig {
+			return s.name
+		}
+	}
+	return ""
+}
+
+// signalLog - сигналы, уже обработанные шеллом. Сигнал доходит до шелла асинхронно,
+// поэтому kill, посланный самому шеллу, дожидается обработки своего сигнала:
+// обработчик trap тогда выполняется сразу после kill, а не когда-нибудь позже
+type signalLog struct {
+	once    sync.Once
+	mu      sync.Mutex
+	enabled bool                   // сигналы перехватываются (initSignals уже вызвана)
+	counts  map[syscall.Signal]int // число обработанных сигналов каждого вида
+	changed chan struct{}          // закрывается и заменяется после обработки каждого сигнала
+}
+
+// shellSignals - сигналы, обработанные шеллом
+var shellSignals = &signalLog{counts: map[syscall.Signal]int{}, changed: make(chan struct{})}
+
+// initSignals включает перехват сигналов шелла. Повторные вызовы ничего не делают
+func initSignals() {
+	shellSignals.once.Do(func() {
+		sigs := make(chan os.Signal, 4)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+		shellSignals.mu.Lock()
+		shellSignals.enabled = true
+		shellSignals.mu.Unlock()
+		go func() {
+			for sig := range sigs {
+				sig := sig.(syscall.Signal)
+				jobs.signalForeground(sig)
+				switch sig {
+				case syscall.SIGINT:
+					traps.signal("INT")
+				case syscall.SIGTERM:
+					traps.signal("TERM")
+				}
+				jobs.wake()
+				shellSignals.add(sig)
+			}
+		}()
+	})
+}
+
+// count возвращает число уже обработанных сигналов sig; ok - шелл перехватывает sig
+func (l *signalLog) count(sig syscall.Signal) (n int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch sig {
+	case syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM:
+		return l.counts[sig], l.enabled
+	}
+	return 0, false
+}
+
+// add отмечает, что сигнал sig обработан
+func (l *signalLog) add(sig syscall.Signal) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[sig]++
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// wait ждет, пока число обработанных сигналов sig превысит n. Сигнал, потерянный
+// при переполнении очереди, ожидание не задерживает надолго
+func (l *signalLog) wait(sig syscall.Signal, n int) {
+	timeout := time.After(time.Second)
+	for {
+		l.mu.Lock()
+		count, changed := l.counts[sig], l.changed
+		l.mu.Unlock()
+		if count > n {
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// signal отмечает получение сигнала name (INT или TERM). Если для него задан обработчик,
+// тот выполнится между командами; иначе Ctrl-C прерывает текущую строку,
+// а SIGTERM завершает неинтерактивный шелл (интерактивный его игнорирует, как bash)
+func (t *trapTable) signal(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	action, ok := t.actions[name]
+	switch {
+	case ok && action != "":
+		t.pending = append(t.pending, name)
+	case ok: // trap '' - сигнал игнорируется
+	case name == "INT":
+		t.interrupted = true
+	case name == "TERM" && !interactive:
+		t.interrupted = true
+		vars.requestExit(128 + int(syscall.SIGTERM))
+	}
+}
+
+// interrupt прерывает выполнение текущей строки, как Ctrl-C
+func (t *trapTable) interrupt() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interrupted = true
+}
+
+// isInterrupted сообщает, что выполнение прервано Ctrl-C
+func (t *trapTable) isInterrupted() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.interrupted
+}
+
+// isSignaled сообщает, что выполнение прервано Ctrl-C или получен сигнал с обработчиком:
+// ожидание wait в обоих случаях прекращается
+func (t *trapTable) isSignaled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.interrupted || len(t.pending) > 0
+}
+
+// takeInterrupted сообщает, было ли выполнение прервано Ctrl-C, и сбрасывает прерывание
+func (t *trapTable) takeInterrupted() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	interrupted := t.interrupted
+	t.interrupted = false
+	return interrupted
+}
+
+// set задает обработчик сигнала; action "-" восстанавливает действие по умолчанию
+func (t *trapTable) set(name, action string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if action == "-" {
+		delete(t.actions, name)
+		return
+	}
+	t.actions[name] = action
+}
+
+// runPending выполняет обработчики полученных сигналов. $? после обработчика не меняется
+func (t *trapTable) runPending(std stdio) {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	for _, name := range pending {
+		t.run(name, std, nil)
+	}
+}
+
+// runExit выполняет обработчики сигналов, еще ожидающие обработки, и обработчик EXIT
+// перед завершением шелла с кодом возврата status. Возвращает код возврата шелла:
+// exit внутри обработчиков заменяет его
+func (t *trapTable) runExit(std stdio, status int) int {
+	t.runPending(std)
+	if code, ok := vars.takeExit(); ok {
+		status = code
+	}
+	t.takeInterrupted()
+	vars.setStatus(status)
+	t.run("EXIT", std, nil)
+	t.set("EXIT", "-")
+	if code, ok := vars.takeExit(); ok {
+		return code
+	}
+	return status
+}
+
+// run выполняет обработчик сигнала name, если он задан, в задании j (nil - в шелле)
+func (t *trapTable) run(name string, std stdio, j *job) {
+	t.mu.Lock()
+	action := t.actions[name]
+	t.mu.Unlock()
+	if action == "" {
+		return
+	}
+	s := j.scope()
+	l, err := s.parse(action)
+	if err != nil {
+		reportError(std.err, "trap", err)
+		return
+	}
+	status := s.vars.lastStatus()
+	execList(l, std, j)
+	s.vars.setStatus(status)
+}
+
+// signalName приводит обозначение сигнала для trap (INT, SIGINT, 2) к имени из trapSignals
+func signalName(spec string) (string, bool) {
+	name := strings.TrimPrefix(strings.ToUpper(spec), "SIG")
+	if _, ok := trapSignals[name]; ok {
+		return name, true
+	}
+	if n, err := strconv.Atoi(spec); err == nil {
+		for name, number := range trapSignals {
+			if number == n {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// processTrap эмулирует команду trap: "trap команды сигнал..." задает обработчик,
+// "trap - сигнал..." восстанавливает действие по умолчанию, trap с пустой строкой команд
+// игнорирует сигнал, без аргументов (или с -p) выводит заданные обработчики
+func processTrap(args []string, std stdio, s *scope) error {
+	if len(args) == 0 || args[0] == "-p" {
+		s.traps.mu.Lock()
+		names := make([]string, 0, len(s.traps.actions))
+		for name := range s.traps.actions {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, k int) bool { return trapSignals[names[i]] < trapSignals[names[k]] })
+		for _, name := range names {
+			fmt.Fprintf(std.out, "trap -- '%s' %s\n", strings.ReplaceAll(s.traps.actions[name], "'", `'\''`), name)
+		}
+		s.traps.mu.Unlock()
+		return nil
+	}
+
+	action, specs := args[0], args[1:]
+	if len(specs) == 0 { // "trap INT" - то же, что "trap - INT"
+		action, specs = "-", args
+	}
+	var res error
+	for _, spec := range specs {
+		name, ok := signalName(spec)
+		if !ok {
+			reportError(std.err, "trap", fmt.Errorf("%s: invalid signal specification", spec))
+			res = statusError(1)
+			continue
+		}
+		s.traps.set(name, action)
+	}
+	return res
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTrap(t *testing.T) {
+	initSignals()
+	defer traps.set("INT", "-")
+	defer traps.set("TERM", "-")
+
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"trap 'echo bye' EXIT; echo a", 0, "a\nbye\n"},
+		{"trap 'echo bye; exit 4' 0; true", 4, "bye\n"},
+		{"trap 'echo bye $?' EXIT; exit 3", 3, "bye 3\n"},
+		{"trap 'echo bye' EXIT; trap - EXIT; echo a", 0, "a\n"},
+		{"trap 'echo it'\\''s' SIGINT; trap 'echo t' 15; trap; trap INT; trap -p", 0,
+			"trap -- 'echo it'\\''s' INT\ntrap -- 'echo t' TERM\ntrap -- 'echo t' TERM\n"},
+		{"trap 'echo x' HUP", 1, "myshell: trap: HUP: invalid signal specification\n"},
+		{"trap 'echo term' TERM; kill -s TERM $$; echo still", 0, "term\nstill\n"},
+		{"trap 'echo term; exit 5' TERM; kill $$", 5, "term\n"},
+		{"trap 'echo term' TERM; trap 'echo bye' EXIT; kill -s TERM $$", 0, "term\nbye\n"},
+		{"trap '' TERM; kill -s TERM $$; echo still", 0, "still\n"},
+		{"trap - TERM; kill -s TERM $$; echo no", 143, ""},
+	}
+
+	for _, testCase := range testCases {
+		out := &bytes.Buffer{}
+		status := runScript(testCase.input, "-c", stdio{in: strings.NewReader(""), out: out, err: out})
+		if status != testCase.status || out.String() != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, out.String())
+		}
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	defer traps.takeInterrupted()
+
+	traps.signal("INT")
+	status, output := run(t, "echo a; echo b")
+	if status != 0 || output != "" {
+		t.Errorf("expected interrupted line to do nothing, got: %d %q", status, output)
+	}
+	traps.takeInterrupted()
+
+	traps.set("INT", "echo handled")
+	defer traps.set("INT", "-")
+	traps.signal("INT")
+	if status, output := run(t, "echo a"); status != 0 || output != "handled\na\n" {
+		t.Errorf("expected trap before the command, got: %d %q", status, output)
+	}
+}
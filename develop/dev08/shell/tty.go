@@ -0,0 +1,81 @@
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl выполняет системный вызов ioctl с указателем в качестве аргумента
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isTerminal сообщает, является ли дескриптор fd терминалом
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctl(fd, syscall.TCGETS, unsafe.Pointer(&termios)) == nil
+}
+
+// getTermios возвращает настройки терминала
+func getTermios(fd int) (*syscall.Termios, error) {
+	termios := &syscall.Termios{}
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(termios)); err != nil {
+		return nil, err
+	}
+	return termios, nil
+}
+
+// setTermios применяет настройки терминала
+func setTermios(fd int, termios *syscall.Termios) error {
+	return ioctl(fd, syscall.TCSETS, unsafe.Pointer(termios))
+}
+
+// enableRawMode переводит терминал в неканонический режим без эха и обработки
+// управляющих символов, чтобы редактор строки получал каждую клавишу.
+// Возвращает прежние настройки для восстановления
+func enableRawMode(fd int) (*syscall.Termios, error) {
+	old, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+	raw := *old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.BRKINT | syscall.INPCK | syscall.ISTRIP
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := setTermios(fd, &raw); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// tcgetpgrp возвращает группу процессов, активную на терминале fd
+func tcgetpgrp(fd int) (int, error) {
+	var pgid int32
+	if err := ioctl(fd, syscall.TIOCGPGRP, unsafe.Pointer(&pgid)); err != nil {
+		return 0, err
+	}
+	return int(pgid), nil
+}
+
+// tcsetpgrp делает группу процессов pgid активной на терминале fd.
+// На время вызова в текущем потоке блокируется SIGTTOU: иначе шелл, сам находящийся
+// в этот момент в фоновой группе, получил бы этот сигнал вместо передачи терминала.
+func tcsetpgrp(fd int, pgid int) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	const sigBlock, sigSetmask = 0, 2
+	set := uint64(1) << (uint(syscall.SIGTTOU) - 1)
+	var old uint64
+	syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, sigBlock, uintptr(unsafe.Pointer(&set)), uintptr(unsafe.Pointer(&old)), 8, 0, 0)
+	defer syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, sigSetmask, uintptr(unsafe.Pointer(&old)), 0, 8, 0, 0)
+
+	pg := int32(pgid)
+	return ioctl(fd, syscall.TIOCSPGRP, unsafe.Pointer(&pg))
+}
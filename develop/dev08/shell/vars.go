@@ -0,0 +1,480 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// variables - переменные шелла. Переменные окружения, унаследованные при запуске,
+// становятся экспортируемыми переменными шелла; внешние команды получают
+// только экспортируемые переменные.
+type variables struct {
+	mu       sync.Mutex
+	values   map[string]string
+	exported map[string]bool
+	status   int // $? - код возврата последнего конвейера
+	lastBg   int // $! - группа процессов последнего фонового задания, 0 - фоновых заданий не было
+	options  map[string]bool
+	name     string   // $0 - имя шелла или скрипта
+	params   []string // позиционные параметры $1, $2, ...
+	exiting  bool     // вызван exit: шелл завершается после текущей команды
+	exitCode int      // код возврата для exit
+}
+
+// vars - переменные шелла
+var vars = newVariables(os.Environ())
+
+// newVariables создает переменные из окружения вида NAME=value
+func newVariables(environ []string) *variables {
+	v := &variables{values: map[string]string{}, exported: map[string]bool{}, options: map[string]bool{}, name: "myshell"}
+	for _, kv := range environ {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			v.values[kv[:i]] = kv[i+1:]
+			v.exported[kv[:i]] = true
+		}
+	}
+	return v
+}
+
+// clone возвращает копию переменных для подоболочки: значения, экспорт, параметры шелла,
+// $?, $!, $0 и позиционные параметры. Запрос exit не копируется
+func (v *variables) clone() *variables {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c := &variables{values: map[string]string{}, exported: map[string]bool{}, options: map[string]bool{},
+		status: v.status, lastBg: v.lastBg, name: v.name, params: append([]string(nil), v.params...)}
+	for name, value := range v.values {
+		c.values[name] = value
+	}
+	for name := range v.exported {
+		c.exported[name] = true
+	}
+	for name, on := range v.options {
+		c.options[name] = on
+	}
+	return c
+}
+
+// isName сообщает, является ли строка допустимым именем переменной
+func isName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// get возвращает значение переменной, включая специальные $?, $$, $!, $0, $#, $@, $*
+// и позиционные параметры
+func (v *variables) get(name string) (string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if n, err := strconv.Atoi(name); err == nil && name[0] >= '1' && name[0] <= '9' {
+		if n > len(v.params) {
+			return "", false
+		}
+		return v.params[n-1], true
+	}
+	switch name {
+	case "?":
+		return strconv.Itoa(v.status), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	case "!":
+		if v.lastBg == 0 {
+			return "", false
+		}
+		return strconv.Itoa(v.lastBg), true
+	case "0":
+		return v.name, true
+	case "#":
+		return strconv.Itoa(len(v.params)), true
+	case "@", "*":
+		return strings.Join(v.params, " "), true
+	}
+	value, ok := v.values[name]
+	return value, ok
+}
+
+// set задает значение переменной; экспортируемая переменная остается экспортируемой
+func (v *variables) set(name, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[name] = value
+}
+
+// export отмечает переменную как экспортируемую
+func (v *variables) export(name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.exported[name] = true
+}
+
+// unset удаляет переменную
+func (v *variables) unset(name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.values, name)
+	delete(v.exported, name)
+}
+
+// positional возвращает позиционные параметры
+func (v *variables) positional() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.params
+}
+
+// setPositional заменяет позиционные параметры, возвращает прежние
+func (v *variables) setPositional(params []string) []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	old := v.params
+	v.params = params
+	return old
+}
+
+// setName задает $0
+func (v *variables) setName(name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.name = name
+}
+
+// requestExit запоминает, что шелл должен завершиться с кодом возврата status
+func (v *variables) requestExit(status int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.exiting, v.exitCode = true, status
+}
+
+// isExiting сообщает, что вызван exit и выполнение команд нужно прекратить
+func (v *variables) isExiting() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.exiting
+}
+
+// takeExit возвращает код возврата exit, если он был вызван, и сбрасывает запрос
+func (v *variables) takeExit() (int, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	exiting := v.exiting
+	v.exiting = false
+	return v.exitCode, exiting
+}
+
+// setStatus запоминает код возврата для $?
+func (v *variables) setStatus(status int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.status = status
+}
+
+// lastStatus возвращает код возврата последнего конвейера ($?)
+func (v *variables) lastStatus() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.status
+}
+
+// setLastBackground запоминает группу процессов фонового задания для $!
+func (v *variables) setLastBackground(pgid int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.lastBg = pgid
+}
+
+// option сообщает, включен ли параметр шелла (set -o)
+func (v *variables) option(name string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.options[name]
+}
+
+// setOption включает или выключает параметр шелла
+func (v *variables) setOption(name string, on bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.options[name] = on
+}
+
+// environ возвращает окружение для внешней команды: экспортируемые переменные
+// и присваивания assigns вида NAME=value, указанные перед командой
+func (v *variables) environ(assigns []string) []string {
+	v.mu.Lock()
+	values := map[string]string{}
+	for name := range v.exported {
+		if value, ok := v.values[name]; ok {
+			values[name] = value
+		}
+	}
+	v.mu.Unlock()
+
+	for _, kv := range assigns {
+		i := strings.IndexByte(kv, '=')
+		values[kv[:i]] = kv[i+1:]
+	}
+	env := make([]string, 0, len(values))
+	for name, value := range values {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// errNotFound - команда не найдена ни в одной директории $PATH
+var errNotFound = errors.New("command not found")
+
+// lookPath ищет исполняемый файл команды name в директориях $PATH.
+// Имя, содержащее /, используется как путь без поиска. Относительный путь
+// отсчитывается от рабочей директории s
+func (s *scope) lookPath(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return s.path(name), checkExecutable(s.path(name))
+	}
+	path, _ := s.vars.get("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		file := s.path(filepath.Join(dir, name))
+		if checkExecutable(file) == nil {
+			return file, nil
+		}
+	}
+	return "", errNotFound
+}
+
+// checkExecutable проверяет, что файл существует, не является директорией и исполняемый
+func checkExecutable(file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return pathErr.Err
+		}
+		return err
+	}
+	if info.IsDir() {
+		return syscall.EISDIR
+	}
+	if info.Mode()&0111 == 0 {
+		return syscall.EACCES
+	}
+	return nil
+}
+
+// processExport эмулирует команду export: NAME=value задает и экспортирует переменную,
+// NAME экспортирует существующую, без аргументов выводит экспортируемые переменные
+func processExport(args []string, std stdio, s *scope) error {
+	if len(args) == 0 || args[0] == "-p" {
+		for _, kv := range s.vars.environ(nil) {
+			i := strings.IndexByte(kv, '=')
+			fmt.Fprintf(std.out, "export %s=%s\n", kv[:i], strconv.Quote(kv[i+1:]))
+		}
+		return nil
+	}
+
+	var res error
+	for _, arg := range args {
+		name, value := arg, ""
+		i := strings.IndexByte(arg, '=')
+		if i >= 0 {
+			name, value = arg[:i], arg[i+1:]
+		}
+		if !isName(name) {
+			reportError(std.err, "export", fmt.Errorf("`%s': not a valid identifier", arg))
+			res = statusError(1)
+			continue
+		}
+		if i >= 0 {
+			s.vars.set(name, value)
+		}
+		s.vars.export(name)
+	}
+	return res
+}
+
+// processUnset эмулирует команду unset: удаляет переменные, с флагом -f - функции
+func processUnset(args []string, std stdio, s *scope) error {
+	var res error
+	function := false
+	for _, name := range args {
+		switch name {
+		case "-v":
+			continue
+		case "-f":
+			function = true
+			continue
+		}
+		if function {
+			s.functions.unset(name)
+			continue
+		}
+		if !isName(name) {
+			reportError(std.err, "unset", fmt.Errorf("`%s': not a valid identifier", name))
+			res = statusError(1)
+			continue
+		}
+		s.vars.unset(name)
+	}
+	return res
+}
+
+// shellOptions - параметры шелла, которые переключает команда set -o/+o
+var shellOptions = []string{"pipefail"}
+
+// processSet эмулирует команду set: "set -o name" включает параметр, "set +o name" выключает,
+// "set -o" без имени выводит состояние всех параметров
+func processSet(args []string, std stdio, s *scope) error {
+	if len(args) == 0 || len(args) == 1 && (args[0] == "-o" || args[0] == "+o") {
+		for _, name := range shellOptions {
+			state := "off"
+			if s.vars.option(name) {
+				state = "on"
+			}
+			fmt.Fprintf(std.out, "%-15s\t%s\n", name, state)
+		}
+		return nil
+	}
+	for i := 0; i < len(args); i++ {
+		if args[i] != "-o" && args[i] != "+o" || i+1 >= len(args) {
+			return fmt.Errorf("%s: invalid option", args[i])
+		}
+		name := args[i+1]
+		if !isOption(name) {
+			return fmt.Errorf("%s: invalid option name", name)
+		}
+		s.vars.setOption(name, args[i] == "-o")
+		i++
+	}
+	return nil
+}
+
+// isOption сообщает, есть ли параметр шелла с таким именем
+func isOption(name string) bool {
+	for _, option := range shellOptions {
+		if option == name {
+			return true
+		}
+	}
+	return false
+}
+
+// processRead эмулирует команду read: читает строку из std.in и присваивает ее поля,
+// разделенные пробелами и табуляциями, переменным names (последней - остаток строки);
+// без имен строка целиком попадает в REPLY. Без -r обратная косая черта экранирует
+// следующий символ, а \ в конце строки продолжает ее на следующей. В конце ввода код возврата 1
+func processRead(args []string, std stdio, s *scope) error {
+	raw := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		if args[0] != "-r" {
+			return fmt.Errorf("%s: invalid option", args[0])
+		}
+		raw = true
+		args = args[1:]
+	}
+	for _, name := range args {
+		if !isName(name) {
+			return fmt.Errorf("`%s': not a valid identifier", name)
+		}
+	}
+
+	line, escaped, eof := readLine(std.in, raw)
+	if len(args) == 0 {
+		s.vars.set("REPLY", string(line))
+	} else {
+		fields := splitLine(line, escaped, len(args))
+		for i, name := range args {
+			value := ""
+			if i < len(fields) {
+				value = fields[i]
+			}
+			s.vars.set(name, value)
+		}
+	}
+	if eof {
+		return statusError(1)
+	}
+	return nil
+}
+
+// readLine читает строку по одному байту, чтобы не забрать ввод следующих команд.
+// Возвращает байты строки, признаки их экранирования и признак конца ввода до перевода строки
+func readLine(in io.Reader, raw bool) ([]byte, []bool, bool) {
+	var line []byte
+	var escaped []bool
+	b := make([]byte, 1)
+	escape := false
+	for {
+		n, err := in.Read(b)
+		if n == 0 {
+			if err != nil {
+				return line, escaped, true
+			}
+			continue
+		}
+		switch {
+		case escape && b[0] == '\n': // продолжение строки
+			escape = false
+		case escape:
+			line = append(line, b[0])
+			escaped = append(escaped, true)
+			escape = false
+		case b[0] == '\n':
+			return line, escaped, false
+		case b[0] == '\\' && !raw:
+			escape = true
+		default:
+			line = append(line, b[0])
+			escaped = append(escaped, false)
+		}
+	}
+}
+
+// splitLine делит строку read на n полей по неэкранированным пробелам и табуляциям;
+// последнее поле - остаток строки без пробелов по краям
+func splitLine(line []byte, escaped []bool, n int) []string {
+	separator := func(i int) bool { return !escaped[i] && (line[i] == ' ' || line[i] == '\t') }
+	var fields []string
+	i := 0
+	for len(fields) < n {
+		for i < len(line) && separator(i) {
+			i++
+		}
+		if i == len(line) {
+			break
+		}
+		start := i
+		if len(fields) == n-1 {
+			end := len(line)
+			for end > start && separator(end-1) {
+				end--
+			}
+			fields = append(fields, string(line[start:end]))
+			break
+		}
+		for i < len(line) && !separator(i) {
+			i++
+		}
+		fields = append(fields, string(line[start:i]))
+	}
+	return fields
+}
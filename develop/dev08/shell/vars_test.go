@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	vars.set("TV_NAME", "value")
+	vars.set("TV_EMPTY", "")
+	vars.unset("TV_UNSET")
+	vars.setStatus(3)
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"$TV_NAME", "value"},
+		{"${TV_NAME}x", "valuex"},
+		{"$TV_NAMEx", ""},
+		{"a-$TV_NAME-b", "a-value-b"},
+		{"${TV_UNSET:-def}", "def"},
+		{"${TV_EMPTY:-def}", "def"},
+		{"${TV_EMPTY-def}", ""},
+		{"${TV_UNSET-$TV_NAME}", "value"},
+		{"$?", "3"},
+		{"$$", strconv.Itoa(os.Getpid())},
+		{"cost $ 5", "cost $ 5"},
+		{"end$", "end$"},
+	}
+
+	e := newExpander(stdio{}, nil)
+	for _, testCase := range testCases {
+		if res := e.text(testCase.input); res != testCase.expected {
+			t.Errorf("testing %q, expected: %q, got: %q", testCase.input, testCase.expected, res)
+		}
+	}
+}
+
+func TestParseAssignments(t *testing.T) {
+	l, err := parse(`A=1 B="x y" cmd C=2`)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	c := l.items[0].andOr.pipelines[0].commands[0].(*simpleCommand)
+	if len(c.assigns) != 2 || c.assigns[0].name != "A" || c.assigns[1].name != "B" || c.assigns[1].value.String() != "x y" {
+		t.Errorf("unexpected assignments: %+v", c.assigns)
+	}
+	if got := words(c); !reflect.DeepEqual(got, []string{"cmd", "C=2"}) {
+		t.Errorf("expected words cmd C=2, got: %q", got)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	path, _ := vars.get("PATH")
+	defer vars.set("PATH", path)
+
+	testCases := []struct {
+		input  string
+		status int
+		output string
+	}{
+		{"TV_A=1; echo $TV_A; sh -c 'echo [$TV_A]'", 0, "1\n[]\n"},
+		{"TV_B=2 sh -c 'echo $TV_B'; echo [$TV_B]", 0, "2\n[]\n"},
+		{"export TV_C=3; sh -c 'echo $TV_C'", 0, "3\n"},
+		{"TV_D=4; export TV_D; TV_D=5; sh -c 'echo $TV_D'", 0, "5\n"},
+		{"export TV_E=6; unset TV_E; sh -c 'echo [$TV_E]'; echo [$TV_E]", 0, "[]\n[]\n"},
+		{"export 1x=2", 1, "myshell: export: `1x=2': not a valid identifier\n"},
+		{"sleep 0 & wait; test $! -gt 0 && echo ok", 0, "ok\n"},
+		{"cat <<EOF\n$TV_A\nEOF\n", 0, "1\n"},
+		{"echo ' a  b c ' | { read TV_X TV_Y; echo \"[$TV_X][$TV_Y]\"; }", 0, "[a][b c]\n"},
+		{"echo a | { read TV_X TV_Y TV_Z; echo \"[$TV_X][$TV_Y][$TV_Z]\"; }", 0, "[a][][]\n"},
+		{"printf ' x ' | { read; echo \"[$REPLY]\"; }", 0, "[ x ]\n"},
+		{"printf 'a\\\\ b\\\\\\nc d' | { read TV_X TV_Y; echo \"[$TV_X][$TV_Y]\"; }", 0, "[a bc][d]\n"},
+		{"echo 'a\\ b' | { read -r TV_X TV_Y; echo \"[$TV_X][$TV_Y]\"; }", 0, "[a\\][b]\n"},
+		{"read TV_X < /dev/null; echo $?", 0, "1\n"},
+		{"read 1x", 1, "myshell: read: `1x': not a valid identifier\n"},
+		{"PATH=/nonexistent; ls", 127, "myshell: ls: command not found\n"},
+	}
+
+	for _, testCase := range testCases {
+		status, output := run(t, testCase.input)
+		if status != testCase.status || output != testCase.output {
+			t.Errorf("testing %q, expected: %d %q, got: %d %q", testCase.input, testCase.status, testCase.output, status, output)
+		}
+	}
+}